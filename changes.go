@@ -0,0 +1,497 @@
+package deepcopy
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// ChangeKind is the kind of a change made by OnChange.
+type ChangeKind string
+
+const (
+	// ChangeAdded means the value is absent or nil in the destination before the change.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved means the value is absent or nil in the source, so it is removed from the
+	// destination.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeModified means the value is replaced by a different one.
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change describes a value changed in the destination of OnChange.
+type Change struct {
+	// Path is the field path of the value changed, in which slice elements are selected by their
+	// indexes, e.g. "Spec.Containers[1].Image".
+	Path string
+	Kind ChangeKind
+	// Old is the value before the change, which is nil if the value is absent.
+	Old interface{}
+	// New is the value after the change, which is nil if the value is removed.
+	New interface{}
+}
+
+// ChangeSet is all changes made by OnChange, sorted by their paths.
+type ChangeSet []Change
+
+// Paths returns paths of all the changes.
+func (c ChangeSet) Paths() []string {
+	paths := make([]string, 0, len(c))
+	for _, change := range c {
+		paths = append(paths, change.Path)
+	}
+
+	return paths
+}
+
+// OnChangeReport is like OnChangeE but returns all changes made to dst. Changes in a field selected
+// as a whole are reported at paths of the fields, entries and elements changed in it, unless they
+// are compared or copied as a whole by options or tags.
+func OnChangeReport(dst, src interface{}, fieldsSelected ...string) (ChangeSet, error) {
+	return OnChangeReportWith(dst, src, fieldsSelected)
+}
+
+// OnChangeReportWith is like OnChangeReport but configured by opts.
+func OnChangeReportWith(dst, src interface{}, fieldsSelected []string, opts ...Option) (ChangeSet, error) {
+	rec, err := recordChanges(dst, src, fieldsSelected, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rec.changes, func(i, j int) bool {
+		return rec.changes[i].Path < rec.changes[j].Path
+	})
+
+	return rec.changes, nil
+}
+
+// OnChangePreview is like OnChangeReportWith with DryRun, but also returns the pointer to the
+// object dst would become. The object shares values unchanged with dst, so neither should be
+// changed while the other is in use.
+func OnChangePreview(dst, src interface{}, fieldsSelected []string, opts ...Option) (
+	result interface{}, changes ChangeSet, err error) {
+	rec, err := recordChanges(dst, src, fieldsSelected, newOptions(append(opts, DryRun())))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.SliceStable(rec.changes, func(i, j int) bool {
+		return rec.changes[i].Path < rec.changes[j].Path
+	})
+
+	if !rec.result.IsValid() {
+		return dst, rec.changes, nil
+	}
+
+	return rec.result.Interface(), rec.changes, nil
+}
+
+// recordChanges copies fields selected from src into dst on change and returns the recorder of
+// changes in the order they are made.
+func recordChanges(dst, src interface{}, fieldsSelected []string, o *options) (*changeRecorder, error) {
+	hierarchy, err := fieldsToTree(fieldsSelected)
+	if err != nil {
+		return nil, err
+	}
+
+	return recordTreeChanges(dst, src, hierarchy, o)
+}
+
+// recordTreeChanges is like recordChanges but copies fields selected by hierarchy.
+func recordTreeChanges(dst, src interface{}, hierarchy tree, o *options) (rec *changeRecorder, err error) {
+	rec = &changeRecorder{}
+	if err = applyMergeKeys(&hierarchy, o); err != nil {
+		return
+	}
+
+	if o.equality, err = newEqualOptions(o.equalOpts); err != nil {
+		return
+	}
+
+	o.equality.resolver, o.equality.copier = o.resolver, o.copier
+	o.equality.unexported = o.equality.unexported || o.unexported
+	if err = o.parseFieldHooks(); err != nil {
+		return
+	}
+
+	if o.dryRun {
+		// Nothing is changed in dry runs, so there is nothing for hooks to veto.
+		o.fieldHooks = nil
+	}
+
+	if src == nil {
+		return
+	}
+
+	if err = checkObjects(dst, src); err != nil {
+		return
+	}
+
+	if err = validateMergeKeys(reflect.TypeOf(src), o); err != nil {
+		return
+	}
+
+	rec.result = reflect.ValueOf(dst)
+	if hierarchy.selected && !hierarchy.walksThrough(rec.result.Elem(), reflect.ValueOf(src).Elem(), nil, o) {
+		if o.dryRun {
+			rec.result = reflect.New(rec.result.Type().Elem())
+			rec.result.Elem().Set(reflect.ValueOf(dst).Elem())
+		}
+
+		dstV := rec.result.Elem()
+		old := snapshot(dstV)
+		var copied bool
+		copied, err = copyLeafChanges(dstV, reflect.ValueOf(src).Elem(), &hierarchy, copyDeep, false, rec, o)
+		if err == ErrVetoChange {
+			return rec, nil
+		} else if err != nil {
+			return
+		}
+
+		if copied {
+			rec.recordSelected(old, dstV, &hierarchy, o)
+		}
+
+		return
+	}
+
+	rec.result, _, err = copyPieceChanges(rec.result, reflect.ValueOf(src), &hierarchy, &stackTracer{
+		HierarchyStack: HierarchyStack(""),
+		Tracer:         o.tracer,
+	}, rec, o)
+	return
+}
+
+// changeRecorder records changes along with keys of the field path being walked through.
+type changeRecorder struct {
+	keys    []string
+	changes ChangeSet
+	// result is the pointer to the object changed, which is a shallow copy of the destination in
+	// dry runs.
+	result reflect.Value
+	// steps are keys of paths of changes, along with how the changes resize slices.
+	steps []changeStep
+}
+
+// resize tells how a change resizes the slice it is made to.
+type resize int
+
+const (
+	resizeNone resize = iota
+	resizeRemove
+	resizeInsert
+)
+
+type changeStep struct {
+	keys   []string
+	resize resize
+}
+
+func (r *changeRecorder) push(key string) {
+	r.keys = append(r.keys, key)
+}
+
+func (r *changeRecorder) pop() {
+	r.keys = r.keys[:len(r.keys)-1]
+}
+
+// record records the change of the value at the current path from old to new.
+func (r *changeRecorder) record(old, new interface{}) {
+	r.recordResize(old, new, resizeNone)
+}
+
+// recordResize is like record but for a change which resizes the slice the value belongs to.
+func (r *changeRecorder) recordResize(old, new interface{}, how resize) {
+	kind := ChangeModified
+	switch {
+	case isAbsent(old):
+		kind = ChangeAdded
+	case isAbsent(new):
+		kind = ChangeRemoved
+	}
+
+	r.changes = append(r.changes, Change{
+		Path: joinKeys(r.keys),
+		Kind: kind,
+		Old:  old,
+		New:  new,
+	})
+	r.steps = append(r.steps, changeStep{keys: append([]string(nil), r.keys...), resize: how})
+}
+
+// recordSelected records the change of the value selected as a whole at the current path from old
+// to new, which is a deep copy, as changes of the fields, entries and elements changed in it.
+// Values of types compared or copied as a whole are recorded as a whole, as well as those added or
+// removed. hierarchy is the tree applied to the value.
+func (r *changeRecorder) recordSelected(old, new reflect.Value, hierarchy *tree, o *options) {
+	n := len(r.changes)
+	d := &leafDiffer{rec: r, o: o, visited: make(map[comparison]bool)}
+	d.diff(old, new, hierarchy)
+	if len(r.changes) == n {
+		// Values only unequal as a whole, e.g. by unexported fields, are changed as a whole.
+		r.record(old.Interface(), new.Interface())
+	}
+}
+
+// snapshot returns an addressable copy of v, which shares pointers, maps and slices with v.
+func snapshot(v reflect.Value) reflect.Value {
+	cpy := reflect.New(v.Type()).Elem()
+	cpy.Set(v)
+	return cpy
+}
+
+// leafDiffer records changes of values in a value selected as a whole.
+type leafDiffer struct {
+	rec *changeRecorder
+	o   *options
+	// visited are pairs of pointers, maps and slices compared so far in order to terminate on
+	// cycles.
+	visited map[comparison]bool
+}
+
+// diff records changes from old to new at the current path of the recorder. hierarchy is the tree
+// applied to the values, which is nil if there is none.
+func (d *leafDiffer) diff(old, new reflect.Value, hierarchy *tree) {
+	keys := d.rec.keys
+	if d.o.equalAt(keys, old, new) {
+		return
+	}
+
+	if !d.o.divisible(keys, old, new) {
+		d.rec.record(old.Interface(), new.Interface())
+		return
+	}
+
+	switch old.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		key := comparison{old.Pointer(), new.Pointer(), old.Type()}
+		if d.visited[key] {
+			return
+		}
+
+		d.visited[key] = true
+	}
+
+	switch old.Kind() {
+	case reflect.Ptr:
+		d.diff(old.Elem(), new.Elem(), hierarchy)
+	case reflect.Struct:
+		fields, _ := selectableFields(old.Type(), d.o.resolver)
+		for _, name := range fields {
+			sub := branchOf(hierarchy, name)
+			if sub != nil && sub.excluded {
+				continue
+			}
+
+			oldField, _ := fieldByName(old, name, d.o.resolver)
+			newField, _ := fieldByName(new, name, d.o.resolver)
+			d.rec.push(name)
+			d.diff(oldField, newField, sub)
+			d.rec.pop()
+		}
+	case reflect.Map:
+		for _, key := range old.MapKeys() {
+			name, _ := mapKeySegment(key)
+			d.rec.push(name)
+			if newEntry := new.MapIndex(key); newEntry.IsValid() {
+				d.diff(old.MapIndex(key), newEntry, branchOf(hierarchy, name))
+			} else {
+				d.rec.record(old.MapIndex(key).Interface(), nil)
+			}
+
+			d.rec.pop()
+		}
+
+		for _, key := range new.MapKeys() {
+			if !old.MapIndex(key).IsValid() {
+				name, _ := mapKeySegment(key)
+				d.rec.push(name)
+				d.rec.record(nil, new.MapIndex(key).Interface())
+				d.rec.pop()
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		d.diffElements(old, new, hierarchy)
+	}
+}
+
+// diffElements records changes from the slice or array old to new. Elements are matched by their
+// indexes, and changes of slices of different lengths are recorded like those made by OnChange.
+func (d *leafDiffer) diffElements(old, new reflect.Value, hierarchy *tree) {
+	n := old.Len()
+	if new.Len() < n {
+		n = new.Len()
+	}
+
+	for j := 0; j < n; j++ {
+		var sub *tree
+		if hierarchy != nil {
+			if elem, found := hierarchy.elementTree(j, new.Len()); found {
+				sub = &elem
+			}
+		}
+
+		d.rec.push("[" + strconv.Itoa(j) + "]")
+		d.diff(old.Index(j), new.Index(j), sub)
+		d.rec.pop()
+	}
+
+	for j := n; j < new.Len(); j++ {
+		d.rec.push("[" + strconv.Itoa(j) + "]")
+		d.rec.recordResize(nil, new.Index(j).Interface(), resizeInsert)
+		d.rec.pop()
+	}
+
+	for j := old.Len() - 1; j >= n; j-- {
+		d.rec.push("[" + strconv.Itoa(j) + "]")
+		d.rec.recordResize(old.Index(j).Interface(), nil, resizeRemove)
+		d.rec.pop()
+	}
+}
+
+// divisible tells whether old and new at the path made of keys can be compared and copied part by
+// part, i.e. neither is nil and they are not compared or copied as a whole.
+func (o *options) divisible(keys []string, old, new reflect.Value) bool {
+	typ := old.Type()
+	if _, found := o.copier.lookup(typ); found || planOf(typ).copier {
+		return false
+	}
+
+	if o.equality != nil {
+		if _, found := o.equality.typeEquals[typ]; found {
+			return false
+		}
+
+		if rules, found := o.equality.rules.descend(keys); found && rules.equal != nil {
+			return false
+		}
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		// Pointers to values which can't be divided are changed as a whole, so that changes are
+		// made of values of the types at their paths.
+		switch typ.Elem().Kind() {
+		case reflect.Ptr, reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+			return !old.IsNil() && !new.IsNil()
+		default:
+			return false
+		}
+	case reflect.Slice:
+		return !old.IsNil() && !new.IsNil()
+	case reflect.Map:
+		if old.IsNil() || new.IsNil() {
+			return false
+		}
+
+		for _, v := range []reflect.Value{old, new} {
+			for _, key := range v.MapKeys() {
+				if _, ok := mapKeySegment(key); !ok {
+					return false
+				}
+			}
+		}
+
+		return true
+	case reflect.Array:
+		return true
+	case reflect.Struct:
+		if o.unexported && len(planOf(typ).allFields) > len(planOf(typ).fields) {
+			return false
+		}
+
+		_, complete := selectableFields(typ, o.resolver)
+		return complete
+	default:
+		return false
+	}
+}
+
+// branchOf returns the branch key of hierarchy, which is nil if hierarchy or the branch is absent.
+func branchOf(hierarchy *tree, key string) *tree {
+	if hierarchy == nil {
+		return nil
+	}
+
+	return hierarchy.FindBranch(key)
+}
+
+// selectableFields returns names of exported fields of the structure typ in field paths, which
+// are resolved by resolver. Fields of embedded structures inlined by resolver are included instead
+// of the structures. complete is false if any field copied can't be selected by its name.
+func selectableFields(typ reflect.Type, resolver NameResolver) (names []string, complete bool) {
+	complete = true
+	var collect func(t reflect.Type, index []int)
+	collect = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" || parseCopyMode(field.Tag) == copySkip {
+				continue
+			}
+
+			fieldIndex := append(index[:len(index):len(index)], i)
+			name, inline := field.Name, false
+			if resolver != nil {
+				name, inline = resolver.FieldName(field)
+			}
+
+			if inline {
+				fieldTyp := field.Type
+				if fieldTyp.Kind() == reflect.Ptr {
+					fieldTyp = fieldTyp.Elem()
+				}
+
+				if fieldTyp.Kind() == reflect.Struct {
+					collect(fieldTyp, fieldIndex)
+					continue
+				}
+			}
+
+			// Fields shadowed by others of the same name can't be selected.
+			f := planOf(typ).field(typ, name, resolver)
+			if len(name) == 0 || f == nil || !reflect.DeepEqual(f.index, fieldIndex) {
+				complete = false
+				continue
+			}
+
+			names = append(names, name)
+		}
+	}
+
+	collect(typ, nil)
+	return
+}
+
+// mapKeySegment returns key of a map in field paths. ok is false if key can't be in field paths.
+func mapKeySegment(key reflect.Value) (name string, ok bool) {
+	switch key.Kind() {
+	case reflect.String:
+		str := key.String()
+		if segments, err := parsePath(str); err == nil && len(segments) == 1 &&
+			segments[0].kind == segmentField && segments[0].name == str {
+			return str, true
+		}
+
+		return segment{kind: segmentKey, name: str}.key(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return fmtMapKey(key), true
+	default:
+		return "", false
+	}
+}
+
+// isAbsent tells whether v is nil or a nil pointer, map, slice, etc.
+func isAbsent(v interface{}) bool {
+	value := reflect.ValueOf(v)
+	if !value.IsValid() {
+		return true
+	}
+
+	switch value.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return value.IsNil()
+	}
+
+	return false
+}
@@ -0,0 +1,423 @@
+package deepcopy_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestOnChangeReport(t *testing.T) {
+	src := structWithMaps{
+		Labels: map[string]string{"app": "A", "version": "2"},
+		Items:  map[string]simpleStruct{"A": {FieldA: "A", FieldB: 1}},
+	}
+	dst := structWithMaps{
+		Labels: map[string]string{"version": "1", "stale": "true"},
+		Items:  map[string]simpleStruct{"A": {FieldA: "A"}},
+	}
+
+	changes, err := deepcopy.OnChangeReport(&dst, &src, "Labels.app", "Labels.version", "Labels.stale",
+		"Items.A.FieldB", "Items.A.FieldA")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes, deepcopy.ChangeSet{
+		{Path: "Items.A.FieldB", Kind: deepcopy.ChangeModified, Old: 0, New: 1},
+		{Path: "Labels.app", Kind: deepcopy.ChangeAdded, New: "A"},
+		{Path: "Labels.stale", Kind: deepcopy.ChangeRemoved, Old: "true"},
+		{Path: "Labels.version", Kind: deepcopy.ChangeModified, Old: "1", New: "2"},
+	})
+	assert.DeepEqual(t, dst.Labels, src.Labels)
+
+	changes, err = deepcopy.OnChangeReport(&dst, &src, "Labels", "Items")
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+}
+
+func TestOnChangeReportOfSliceElements(t *testing.T) {
+	src := []simpleStruct{{FieldA: "A", FieldB: 1}, {FieldA: "B", FieldB: 2}}
+	dst := []simpleStruct{{FieldA: "A"}, {FieldA: "C"}}
+
+	changes, err := deepcopy.OnChangeReport(&dst, &src, "[*].FieldA", "[0]")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"[0].FieldB", "[1].FieldA"})
+	assert.DeepEqual(t, changes[0], deepcopy.Change{
+		Path: "[0].FieldB",
+		Kind: deepcopy.ChangeModified,
+		Old:  0,
+		New:  1,
+	})
+	assert.Equal(t, dst[1].FieldA, "B")
+	assert.Equal(t, dst[1].FieldB, 0)
+}
+
+func TestOnChangeReportOfFieldsSelectedAsAWhole(t *testing.T) {
+	one, three := 1, 3
+	src := patchedObject{Spec: &patchedSpec{
+		Replicas: &three, Image: "v2", Labels: map[string]string{"app": "B", "a.b": "c"},
+		Items: []simpleStruct{{FieldA: "A", FieldB: 1}, {FieldA: "B"}},
+	}}
+	dst := patchedObject{Name: "A", Spec: &patchedSpec{
+		Replicas: &one, Image: "v1", Labels: map[string]string{"app": "A", "tier": "web"},
+		Items: []simpleStruct{{FieldA: "A"}},
+	}}
+
+	changes, err := deepcopy.OnChangeReport(&dst, &src, "Spec")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes, deepcopy.ChangeSet{
+		{Path: "Spec.Image", Kind: deepcopy.ChangeModified, Old: "v1", New: "v2"},
+		{Path: "Spec.Items[0].FieldB", Kind: deepcopy.ChangeModified, Old: 0, New: 1},
+		{Path: "Spec.Items[1]", Kind: deepcopy.ChangeAdded, New: simpleStruct{FieldA: "B"}},
+		{Path: "Spec.Labels.app", Kind: deepcopy.ChangeModified, Old: "A", New: "B"},
+		{Path: "Spec.Labels.tier", Kind: deepcopy.ChangeRemoved, Old: "web"},
+		{Path: `Spec.Labels["a.b"]`, Kind: deepcopy.ChangeAdded, New: "c"},
+		{Path: "Spec.Replicas", Kind: deepcopy.ChangeModified, Old: &one, New: &three},
+	})
+	assert.DeepEqual(t, dst, patchedObject{Name: "A", Spec: src.Spec})
+
+	dst = patchedObject{}
+	changes, err = deepcopy.OnChangeReport(&dst, &src, "Spec")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"Spec"})
+}
+
+func TestOnChangeOfSlicesInDifferentLengths(t *testing.T) {
+	src := structWithSliceOfPointers{
+		SliceA: []*simpleStruct{{FieldA: "A", FieldB: 1}, {FieldA: "B", FieldB: 2}, {FieldA: "C", FieldB: 3}},
+	}
+
+	var dst structWithSliceOfPointers
+	changes, err := deepcopy.OnChangeReport(&dst, &src, "SliceA.FieldA")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"SliceA[0]", "SliceA[1]", "SliceA[2]"})
+	assert.Equal(t, changes[0].Kind, deepcopy.ChangeAdded)
+	assert.Equal(t, len(dst.SliceA), 3)
+	assert.DeepEqual(t, *dst.SliceA[2], simpleStruct{FieldA: "C"})
+
+	src.SliceA = src.SliceA[:1]
+	src.SliceA[0].FieldA = "D"
+	changes, err = deepcopy.OnChangeReport(&dst, &src, "SliceA.FieldA")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"SliceA[0].FieldA", "SliceA[1]", "SliceA[2]"})
+	assert.Equal(t, changes[1].Kind, deepcopy.ChangeRemoved)
+	assert.Equal(t, len(dst.SliceA), 1)
+	assert.Equal(t, dst.SliceA[0].FieldA, "D")
+
+	longer := []*simpleStruct{{FieldA: "E"}, {FieldA: "F"}}
+	dst.SliceA = longer[:1]
+	src.SliceA = []*simpleStruct{{FieldA: "E"}, {FieldA: "G"}}
+	assert.Assert(t, deepcopy.OnChange(&dst, &src, "SliceA[*]"))
+	assert.Equal(t, dst.SliceA[1].FieldA, "G")
+	assert.Equal(t, longer[1].FieldA, "F")
+
+	dst.SliceA = nil
+	_, err = deepcopy.OnChangeE(&dst, &src, "SliceA[0]", "SliceA[1]")
+	pathErr, ok := err.(*deepcopy.PathError)
+	assert.Assert(t, ok, "%#v", err)
+	assert.Equal(t, pathErr.Index, 1)
+	assert.Assert(t, dst.SliceA == nil)
+
+	// Negative indexes are counted from the end of the destination.
+	dst.SliceA = []*simpleStruct{{FieldA: "A"}, {FieldA: "B"}}
+	src.SliceA = []*simpleStruct{{FieldA: "C"}, {FieldA: "D"}, {FieldA: "E"}}
+	changes, err = deepcopy.OnChangeReport(&dst, &src, "SliceA[-1].FieldA")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"SliceA[1].FieldA"})
+	assert.Equal(t, dst.SliceA[0].FieldA, "A")
+	assert.Equal(t, dst.SliceA[1].FieldA, "D")
+
+	src.SliceA = src.SliceA[:1]
+	_, err = deepcopy.OnChangeE(&dst, &src, "SliceA[-1].FieldA")
+	assert.ErrorContains(t, err, `"SliceA[-1]" selects an element out of range`)
+	assert.Equal(t, dst.SliceA[1].FieldA, "D")
+
+	src.SliceA = nil
+	dst.SliceA = longer
+	assert.Assert(t, deepcopy.OnChange(&dst, &src, "SliceA.FieldA"))
+	assert.Assert(t, dst.SliceA == nil)
+	assert.Assert(t, !deepcopy.OnChange(&dst, &src, "SliceA.FieldA"))
+}
+
+func TestOnChangeWithFieldHooks(t *testing.T) {
+	src := structWithMaps{
+		Labels: map[string]string{"app": "A", "version": "2"},
+		Items:  map[string]simpleStruct{"A": {FieldA: "A", FieldB: 1}},
+	}
+	dst := structWithMaps{
+		Labels: map[string]string{"version": "1", "stale": "true"},
+		Items:  map[string]simpleStruct{"A": {FieldA: "A"}},
+	}
+
+	var called []string
+	record := func(path string, old, new reflect.Value) error {
+		called = append(called, fmt.Sprintf("%s %v %v", path, old.IsValid(), new.IsValid()))
+		return nil
+	}
+
+	veto := func(path string, old, new reflect.Value) error {
+		if path == "Labels.stale" || (new.IsValid() && new.Interface() == 1) {
+			return fmt.Errorf("%s is protected: %w", path, deepcopy.ErrVetoChange)
+		}
+
+		return nil
+	}
+
+	changes, err := deepcopy.OnChangeReportWith(&dst, &src,
+		[]string{"Labels.app", "Labels.version", "Labels.stale", "Items.A.FieldB"},
+		deepcopy.OnField("Labels", record), deepcopy.OnField("Labels", veto), deepcopy.OnField("Items.A", veto))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"Labels.app", "Labels.version"})
+	assert.DeepEqual(t, dst.Labels, map[string]string{"app": "A", "version": "2", "stale": "true"})
+	assert.DeepEqual(t, dst.Items["A"], simpleStruct{FieldA: "A"})
+	assert.Equal(t, len(called), 3)
+	assert.Assert(t, contains(called, "Labels.app false true"))
+	assert.Assert(t, contains(called, "Labels.stale true false"))
+
+	failure := errors.New("failure")
+	copied, err := deepcopy.OnChangeWith(&dst, &src, []string{"Items.A.FieldB"},
+		deepcopy.OnField("Items.A.FieldB", func(path string, old, new reflect.Value) error {
+			assert.Equal(t, old.Interface(), 0)
+			return failure
+		}))
+	assert.Equal(t, err, failure)
+	assert.Assert(t, !copied)
+}
+
+func TestOnChangeWithFieldHooksInFieldsSelected(t *testing.T) {
+	one, twenty := 1, 20
+	src := patchedObject{Name: "B", Spec: &patchedSpec{
+		Replicas: &twenty, Image: "v2", Items: []simpleStruct{{FieldA: "A", FieldB: 1}, {FieldA: "B", FieldB: 2}},
+	}}
+	dst := patchedObject{Name: "A", Spec: &patchedSpec{
+		Replicas: &one, Image: "v1", Items: []simpleStruct{{FieldA: "A"}, {FieldA: "B"}},
+	}}
+
+	var called []string
+	veto := func(path string, old, new reflect.Value) error {
+		called = append(called, path)
+		if new.IsValid() && (new.Kind() == reflect.Ptr && new.Elem().Int() > 10 || new.Interface() == 2) {
+			return fmt.Errorf("%s is protected: %w", path, deepcopy.ErrVetoChange)
+		}
+
+		return nil
+	}
+
+	changes, err := deepcopy.OnChangeReportWith(&dst, &src, []string{"Spec"},
+		deepcopy.OnField("Spec.Replicas", veto), deepcopy.OnField("Spec.Items.FieldB", veto))
+	assert.NilError(t, err)
+	sort.Strings(called)
+	assert.DeepEqual(t, called, []string{"Spec.Items[0].FieldB", "Spec.Items[1].FieldB", "Spec.Replicas"})
+	assert.DeepEqual(t, changes.Paths(), []string{"Spec.Image", "Spec.Items[0].FieldB"})
+	assert.DeepEqual(t, dst, patchedObject{Name: "A", Spec: &patchedSpec{
+		Replicas: &one, Image: "v2", Items: []simpleStruct{{FieldA: "A", FieldB: 1}, {FieldA: "B"}},
+	}})
+}
+
+func TestOnChangeWithFieldHooksInMapsSelected(t *testing.T) {
+	src := structWithMaps{
+		Labels: map[string]string{"app": "A", "version": "2"},
+		Items:  map[string]simpleStruct{"A": {FieldA: "A", FieldB: 1}, "B": {FieldA: "B"}},
+	}
+	dst := structWithMaps{
+		Labels: map[string]string{"version": "1", "stale": "true"},
+		Items:  map[string]simpleStruct{"A": {FieldA: "A"}},
+	}
+
+	var called []string
+	veto := func(path string, old, new reflect.Value) error {
+		called = append(called, fmt.Sprintf("%s %v %v", path, old.IsValid(), new.IsValid()))
+		if !new.IsValid() || new.Interface() == 1 {
+			return fmt.Errorf("%s is protected: %w", path, deepcopy.ErrVetoChange)
+		}
+
+		return nil
+	}
+
+	changes, err := deepcopy.OnChangeReportWith(&dst, &src, []string{"Labels", "Items"},
+		deepcopy.OnField("Labels.stale", veto), deepcopy.OnField("Items.A.FieldB", veto))
+	assert.NilError(t, err)
+	sort.Strings(called)
+	assert.DeepEqual(t, called, []string{"Items.A.FieldB true true", "Labels.stale true false"})
+	assert.DeepEqual(t, changes.Paths(), []string{"Items.B", "Labels.app", "Labels.version"})
+	assert.DeepEqual(t, dst, structWithMaps{
+		Labels: map[string]string{"app": "A", "version": "2", "stale": "true"},
+		Items:  map[string]simpleStruct{"A": {FieldA: "A"}, "B": {FieldA: "B"}},
+	})
+}
+
+func TestOnChangeOfSlicesWithFieldHooks(t *testing.T) {
+	vetoC := func(path string, old, new reflect.Value) error {
+		for _, v := range []reflect.Value{old, new} {
+			if v.IsValid() && v.Elem().FieldByName("FieldA").String() == "C" {
+				return deepcopy.ErrVetoChange
+			}
+		}
+
+		return nil
+	}
+
+	src := structWithSliceOfPointers{
+		SliceA: []*simpleStruct{{FieldA: "A"}, {FieldA: "B"}, {FieldA: "C"}, {FieldA: "D"}},
+	}
+
+	var dst structWithSliceOfPointers
+	changes, err := deepcopy.OnChangeReportWith(&dst, &src, []string{"SliceA.FieldA"}, deepcopy.OnField("SliceA", vetoC))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"SliceA[0]", "SliceA[1]"})
+	assert.Equal(t, len(dst.SliceA), 2)
+
+	dst.SliceA = append(dst.SliceA, &simpleStruct{FieldA: "C"}, &simpleStruct{FieldA: "D"})
+	src.SliceA = src.SliceA[:1]
+	changes, err = deepcopy.OnChangeReportWith(&dst, &src, []string{"SliceA.FieldA"}, deepcopy.OnField("SliceA[*]", vetoC))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"SliceA[3]"})
+	assert.Equal(t, len(dst.SliceA), 3)
+	assert.Equal(t, dst.SliceA[2].FieldA, "C")
+}
+
+func TestOnChangeWithMergeKeysAndFieldHooks(t *testing.T) {
+	src := keyedPod{Containers: []keyedContainer{{Name: "b", Image: "b:2"}, {Name: "c", Image: "c:1"}}}
+	dst := keyedPod{Containers: []keyedContainer{{Name: "a", Image: "a:1"}, {Name: "b", Image: "b:1"}}}
+
+	veto := func(path string, old, new reflect.Value) error {
+		if old.IsValid() && old.FieldByName("Name").String() == "a" {
+			return deepcopy.ErrVetoChange
+		}
+
+		return nil
+	}
+
+	changes, err := deepcopy.OnChangeReportWith(&dst, &src, []string{"Containers"},
+		deepcopy.WithMergeKeys("Containers", "Name"), deepcopy.OnField("Containers", veto))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"Containers[1].Image", "Containers[2]"})
+	assert.DeepEqual(t, dst.Containers, []keyedContainer{
+		{Name: "a", Image: "a:1"}, {Name: "b", Image: "b:2"}, {Name: "c", Image: "c:1"},
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+type embeddedSimpleStruct struct {
+	*simpleStruct
+	Replicas int
+}
+
+func TestOnChangeDryRun(t *testing.T) {
+	two := 2
+	src := structWithMaps{
+		Labels:  map[string]string{"app": "A", "version": "2"},
+		Items:   map[string]simpleStruct{"A": {FieldA: "A", FieldB: 1}},
+		Weights: map[int]*simpleStruct{1: {FieldA: "B", FieldB: 2}},
+	}
+	dst := structWithMaps{
+		Labels:  map[string]string{"version": "1", "stale": "true"},
+		Items:   map[string]simpleStruct{"A": {FieldA: "A"}},
+		Weights: map[int]*simpleStruct{1: {FieldA: "B"}},
+	}
+
+	fields := []string{"Labels.app", "Labels.version", "Labels.stale", "Items.A.FieldB", "Weights.1.FieldB"}
+	before := deepcopy.Copy(dst).(structWithMaps)
+	result, changes, err := deepcopy.OnChangePreview(&dst, &src, fields)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, dst, before)
+
+	dryRun, err := deepcopy.OnChangeReportWith(&dst, &src, fields, deepcopy.DryRun())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, dryRun, changes)
+	assert.DeepEqual(t, dst, before)
+
+	copied, err := deepcopy.OnChangeWith(&dst, &src, fields, deepcopy.DryRun())
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.DeepEqual(t, dst, before)
+
+	applied, err := deepcopy.OnChangeReport(&dst, &src, fields...)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes, applied)
+	assert.DeepEqual(t, *result.(*structWithMaps), dst)
+
+	slices := structWithSliceOfPointers{
+		SliceA:   []*simpleStruct{{FieldA: "A"}, {FieldA: "B"}, {FieldA: "C"}},
+		Replicas: &two,
+	}
+	grown := structWithSliceOfPointers{SliceA: []*simpleStruct{{FieldA: "D"}, {FieldA: "B"}}}
+	shrunk := structWithSliceOfPointers{SliceA: []*simpleStruct{{FieldA: "E"}}}
+	for _, src := range []structWithSliceOfPointers{grown, shrunk} {
+		dst := deepcopy.Copy(slices).(structWithSliceOfPointers)
+		result, changes, err := deepcopy.OnChangePreview(&dst, &src, []string{"SliceA.FieldA", "Replicas"})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, dst, slices)
+
+		applied, err := deepcopy.OnChangeReport(&dst, &src, "SliceA.FieldA", "Replicas")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, changes, applied)
+		assert.DeepEqual(t, *result.(*structWithSliceOfPointers), dst)
+	}
+
+	pod := keyedPod{Containers: []keyedContainer{{Name: "a", Image: "a:1"}, {Name: "b", Image: "b:1"}}}
+	update := keyedPod{Containers: []keyedContainer{{Name: "b", Image: "b:2"}, {Name: "c", Image: "c:1"}}}
+	podBefore := deepcopy.Copy(pod).(keyedPod)
+	result, changes, err = deepcopy.OnChangePreview(&pod, &update, []string{"Containers.Image"},
+		deepcopy.WithMergeKeys("Containers", "Name"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"Containers[0]", "Containers[1]", "Containers[1].Image"})
+	assert.DeepEqual(t, pod, podBefore)
+	assert.DeepEqual(t, result.(*keyedPod).Containers, []keyedContainer{{Name: "b", Image: "b:2"}, {Name: "c", Image: "c:1"}})
+
+	elements := []simpleStruct{{FieldA: "A"}, {FieldA: "C"}}
+	result, changes, err = deepcopy.OnChangePreview(&elements, &[]simpleStruct{{FieldA: "B"}}, []string{"[*]"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"[0].FieldA", "[1]"})
+	assert.DeepEqual(t, elements, []simpleStruct{{FieldA: "A"}, {FieldA: "C"}})
+	assert.DeepEqual(t, *result.(*[]simpleStruct), []simpleStruct{{FieldA: "B"}})
+
+	embedding := embeddedSimpleStruct{simpleStruct: &simpleStruct{FieldA: "A"}}
+	result, changes, err = deepcopy.OnChangePreview(&embedding,
+		&embeddedSimpleStruct{simpleStruct: &simpleStruct{FieldA: "B"}, Replicas: 1}, []string{"FieldA", "Replicas"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"FieldA", "Replicas"})
+	assert.Equal(t, embedding.FieldA, "A")
+	assert.Equal(t, result.(*embeddedSimpleStruct).FieldA, "B")
+
+	var called []string
+	veto := deepcopy.OnField("Labels", func(path string, old, new reflect.Value) error {
+		called = append(called, path)
+		return deepcopy.ErrVetoChange
+	})
+
+	base := structWithMaps{Labels: map[string]string{"app": "A"}}
+	relabeled := structWithMaps{Labels: map[string]string{"app": "B"}}
+	result, changes, err = deepcopy.OnChangePreview(&base, &relabeled, []string{"Labels"}, veto)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"Labels.app"})
+	assert.Equal(t, result.(*structWithMaps).Labels["app"], "B")
+
+	patch, err := deepcopy.DiffPatchWith(&base, &relabeled, []string{"Labels"}, veto)
+	assert.NilError(t, err)
+	assert.Assert(t, len(patch) > 2)
+
+	patch, err = deepcopy.MergePatchWith(&base, &relabeled, []string{"Labels"}, veto)
+	assert.NilError(t, err)
+	assert.Assert(t, len(patch) > 2)
+
+	merged, _, err := deepcopy.ThreeWayMergeWith(&base, &base, &relabeled, []string{"Labels"}, veto)
+	assert.NilError(t, err)
+	assert.Equal(t, merged.(*structWithMaps).Labels["app"], "B")
+	assert.Equal(t, len(called), 0)
+
+	copied, err = deepcopy.OnChangeWith(&base, &relabeled, []string{"Labels"}, veto)
+	assert.NilError(t, err)
+	assert.Assert(t, !copied)
+	assert.DeepEqual(t, called, []string{"Labels"})
+}
@@ -0,0 +1,75 @@
+package deepcopy
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// CopierFunc returns a deep copy of src. The copy must be assignable to the type of src. If the
+// copy is the zero Value, the zero value of the type is used.
+type CopierFunc func(src reflect.Value) reflect.Value
+
+// Copier is a registry of copiers for specific types, which are used instead of copying values
+// of the types field by field. It is safe for concurrent use.
+type Copier struct {
+	copiers sync.Map
+}
+
+// NewCopier creates a registry only containing built-in copiers, e.g. the one copies time.Time
+// by assignment. Pass it to functions via WithCopier to scope copiers registered in it.
+func NewCopier() *Copier {
+	c := &Copier{}
+	c.Register(reflect.TypeOf(time.Time{}), func(src reflect.Value) reflect.Value {
+		return src
+	})
+
+	return c
+}
+
+// Register registers copier for values of typ, which replaces the one registered before.
+// It returns c itself for chaining.
+func (c *Copier) Register(typ reflect.Type, copier CopierFunc) *Copier {
+	c.copiers.Store(typ, copier)
+	return c
+}
+
+// Copy is like the function Copy but uses copiers registered in c.
+func (c *Copier) Copy(src interface{}) interface{} {
+	cpy, err := c.CopyE(src)
+	if err != nil {
+		panic(err)
+	}
+
+	return cpy
+}
+
+// CopyE is like Copy but returns an error instead of panicking.
+func (c *Copier) CopyE(src interface{}) (interface{}, error) {
+	return CopyWithOptions(src, WithCopier(c))
+}
+
+func (c *Copier) lookup(typ reflect.Type) (CopierFunc, bool) {
+	copier, found := c.copiers.Load(typ)
+	if !found {
+		return nil, false
+	}
+
+	return copier.(CopierFunc), true
+}
+
+// defaultCopier is the registry used if no one else is specified.
+var defaultCopier = NewCopier()
+
+// RegisterCopier registers copier for values of typ globally, which is used by all functions
+// unless another registry is specified via WithCopier.
+func RegisterCopier(typ reflect.Type, copier CopierFunc) {
+	defaultCopier.Register(typ, copier)
+}
+
+// RegisterCopierFor is like RegisterCopier but for values of type T.
+func RegisterCopierFor[T any](copier func(src T) T) {
+	RegisterCopier(reflect.TypeOf((*T)(nil)).Elem(), func(src reflect.Value) reflect.Value {
+		return reflect.ValueOf(copier(src.Interface().(T)))
+	})
+}
@@ -0,0 +1,66 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type opaqueHandle struct {
+	ID    int
+	cache map[string]string
+}
+
+type structWithHandles struct {
+	Handle  opaqueHandle
+	Handles []*opaqueHandle
+	Created time.Time
+}
+
+func TestRegisteredCopiers(t *testing.T) {
+	copies := 0
+	deepcopy.RegisterCopierFor(func(src opaqueHandle) opaqueHandle {
+		copies++
+		return opaqueHandle{ID: src.ID, cache: map[string]string{}}
+	})
+
+	src := structWithHandles{
+		Handle:  opaqueHandle{ID: 1},
+		Handles: []*opaqueHandle{{ID: 2}},
+		Created: time.Now(),
+	}
+
+	cpy := deepcopy.Copy(src).(structWithHandles)
+	assert.Equal(t, copies, 2)
+	assert.Equal(t, cpy.Handle.ID, 1)
+	assert.Assert(t, cpy.Handle.cache != nil)
+	assert.Assert(t, cpy.Handles[0] != src.Handles[0])
+	assert.Assert(t, cpy.Handles[0].cache != nil)
+	assert.Assert(t, cpy.Created.Equal(src.Created))
+
+	scoped := deepcopy.NewCopier().Register(reflect.TypeOf(&opaqueHandle{}),
+		func(src reflect.Value) reflect.Value {
+			return src
+		})
+	cpy = scoped.Copy(src).(structWithHandles)
+	assert.Equal(t, copies, 2)
+	assert.Assert(t, cpy.Handle.cache == nil)
+	assert.Assert(t, cpy.Handles[0] == src.Handles[0])
+	assert.Assert(t, cpy.Created.Equal(src.Created))
+
+	var dst structWithHandles
+	copied, err := deepcopy.PartialWith(&dst, &src, []string{"Handles"}, deepcopy.WithCopier(scoped))
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.Assert(t, dst.Handles[0] == src.Handles[0])
+
+	mismatched := deepcopy.NewCopier().Register(reflect.TypeOf(opaqueHandle{}),
+		func(src reflect.Value) reflect.Value {
+			return reflect.ValueOf(src.Interface().(opaqueHandle).ID)
+		})
+	_, err = mismatched.CopyE(src)
+	_, ok := err.(*deepcopy.TypeMismatchError)
+	assert.Assert(t, ok)
+}
@@ -1,6 +1,6 @@
 // deepcopy makes deep copies of things. A standard copy will copy the
 // pointers: deep copy copies the values pointed to.  Unexported field
-// values are not copied.
+// values are not copied unless the IncludeUnexported option is given.
 //
 // Copyright (c)2014-2016, Joel Scoble (github.com/mohae), all rights reserved.
 // License: MIT, for more details check the included LICENSE file.
@@ -8,7 +8,7 @@ package deepcopy
 
 import (
 	"reflect"
-	"time"
+	"unsafe"
 )
 
 // Interface for delegating copy process to type
@@ -25,8 +25,24 @@ func Iface(iface interface{}) interface{} {
 // in an interface{}.  The returned value will need to be asserted to the
 // correct type.
 func Copy(src interface{}) interface{} {
+	cpy, err := CopyE(src)
+	if err != nil {
+		panic(err)
+	}
+
+	return cpy
+}
+
+// CopyE is like Copy but returns an error instead of panicking if the copy
+// can't be made, e.g. a DeepCopy method returns a value of some other type.
+func CopyE(src interface{}) (interface{}, error) {
+	return CopyWithOptions(src)
+}
+
+// CopyWithOptions is like CopyE but configured by opts.
+func CopyWithOptions(src interface{}, opts ...Option) (interface{}, error) {
 	if src == nil {
-		return nil
+		return nil, nil
 	}
 
 	// Make the interface a reflect.Value
@@ -36,90 +52,250 @@ func Copy(src interface{}) interface{} {
 	cpy := reflect.New(original.Type()).Elem()
 
 	// Recursively copy the original.
-	copyRecursive(original, cpy)
+	if err := copyRecursive(original, cpy, newOptions(opts)); err != nil {
+		return nil, err
+	}
 
 	// Return the copy as an interface.
-	return cpy.Interface()
+	return cpy.Interface(), nil
+}
+
+// copyRecursive copies original into cpy with a fresh copyState.
+func copyRecursive(original, cpy reflect.Value, o *options) error {
+	return newCopyState(o).copyRecursive(original, cpy)
+}
+
+// copyField copies a field into cpy according to its copyMode with a fresh copyState.
+func copyField(original, cpy reflect.Value, mode copyMode, o *options) error {
+	return newCopyState(o).copyField(original, cpy, mode)
+}
+
+// visit identifies a pointer, map or slice which has been copied. The type is
+// part of the key since a structure and its first field share the address.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// copyState keeps track of pointers, maps and slices copied so far in order to
+// terminate on cycles and reproduce shared references in the copy.
+type copyState struct {
+	visited map[visit]reflect.Value
+	// copier holds copiers registered for specific types.
+	copier *Copier
+	// unexported is true if unexported fields are copied as well via unsafe.
+	unexported bool
+	// uncopyable tells how channels and functions are copied.
+	uncopyable UncopyablePolicy
+}
+
+func newCopyState(o *options) *copyState {
+	return &copyState{
+		visited:    make(map[visit]reflect.Value),
+		copier:     o.copier,
+		unexported: o.unexported,
+		uncopyable: o.uncopyable,
+	}
+}
+
+// copyField copies a field of a structure according to its copyMode.
+func (s *copyState) copyField(original, cpy reflect.Value, mode copyMode) error {
+	switch mode {
+	case copySkip:
+	case copyShallow:
+		cpy.Set(original)
+	case copyZero:
+		cpy.Set(reflect.Zero(cpy.Type()))
+	default:
+		return s.copyRecursive(original, cpy)
+	}
+
+	return nil
 }
 
 // copyRecursive does the actual copying of the interface. It currently has
 // limited support for what it can handle. Add as needed.
-func copyRecursive(original, cpy reflect.Value) {
+func (s *copyState) copyRecursive(original, cpy reflect.Value) error {
+	// Copiers registered for the type take precedence over anything else.
+	if copier, found := s.copier.lookup(original.Type()); found {
+		copied := copier(original)
+		if !copied.IsValid() {
+			return nil
+		}
+
+		if !copied.Type().AssignableTo(cpy.Type()) {
+			return &TypeMismatchError{Src: original.Type(), Dst: copied.Type()}
+		}
+
+		cpy.Set(copied)
+		return nil
+	}
+
+	p := planOf(original.Type())
+
 	// check for implement deepcopy.Interface
-	if original.CanInterface() {
+	if p.copier && original.CanInterface() {
 		if copier, ok := original.Interface().(Interface); ok {
-			cpy.Set(reflect.ValueOf(copier.DeepCopy()))
-			return
+			copied := reflect.ValueOf(copier.DeepCopy())
+			if !copied.IsValid() {
+				return nil
+			}
+
+			if !copied.Type().AssignableTo(cpy.Type()) {
+				return &TypeMismatchError{Src: original.Type(), Dst: copied.Type()}
+			}
+
+			cpy.Set(copied)
+			return nil
 		}
 	}
 
 	// handle according to original's Kind
-	switch original.Kind() {
+	switch p.kind {
 	case reflect.Ptr:
 		// Get the actual value being pointed to.
 		originalValue := original.Elem()
 
-		// if  it isn't valid, return.
+		// if  it isn't valid, the copy is nil as well.
 		if !originalValue.IsValid() {
-			return
+			cpy.Set(reflect.Zero(cpy.Type()))
+			return nil
 		}
+
+		// Reuse the copy if the pointee has been copied already.
+		key := visit{original.Pointer(), original.Type()}
+		if seen, found := s.visited[key]; found {
+			cpy.Set(seen)
+			return nil
+		}
+
 		cpy.Set(reflect.New(originalValue.Type()))
-		copyRecursive(originalValue, cpy.Elem())
+		s.visited[key] = cpy
+		return s.copyRecursive(originalValue, cpy.Elem())
 
 	case reflect.Interface:
-		// If this is a nil, don't do anything
+		// If this is a nil, the copy is nil as well.
 		if original.IsNil() {
-			return
+			cpy.Set(reflect.Zero(cpy.Type()))
+			return nil
 		}
 		// Get the value for the interface, not the pointer.
 		originalValue := original.Elem()
 
 		// Get the value by calling Elem().
 		copyValue := reflect.New(originalValue.Type()).Elem()
-		copyRecursive(originalValue, copyValue)
+		if err := s.copyRecursive(originalValue, copyValue); err != nil {
+			return err
+		}
 		cpy.Set(copyValue)
 
 	case reflect.Struct:
-		t, ok := original.Interface().(time.Time)
-		if ok {
-			cpy.Set(reflect.ValueOf(t))
-			return
+		fields := p.fields
+		if s.unexported {
+			fields = p.allFields
+			// Unexported fields are accessed via their addresses.
+			if !original.CanAddr() {
+				addressable := reflect.New(original.Type()).Elem()
+				addressable.Set(original)
+				original = addressable
+			}
 		}
+
 		// Go through each field of the struct and copy it.
-		for i := 0; i < original.NumField(); i++ {
-			// The Type's StructField for a given field is checked to see if StructField.PkgPath
-			// is set to determine if the field is exported or not because CanSet() returns false
-			// for settable fields.  I'm not sure why.  -mohae
-			if original.Type().Field(i).PkgPath != "" {
-				continue
+		for _, f := range fields {
+			i := f.index[0]
+			originalField, cpyField := original.Field(i), cpy.Field(i)
+			if f.unexported {
+				originalField, cpyField = exposeField(originalField), exposeField(cpyField)
+			}
+
+			if err := s.copyField(originalField, cpyField, f.mode); err != nil {
+				return err
 			}
-			copyRecursive(original.Field(i), cpy.Field(i))
 		}
 
 	case reflect.Slice:
 		if original.IsNil() {
-			return
+			cpy.Set(reflect.Zero(cpy.Type()))
+			return nil
+		}
+
+		// Slices starting at the same element share the backing array of the
+		// copy as long as it is large enough to hold them.
+		key := visit{original.Pointer(), original.Type()}
+		if original.Cap() > 0 {
+			if seen, found := s.visited[key]; found &&
+				original.Len() <= seen.Len() && original.Cap() <= seen.Cap() {
+				cpy.Set(seen.Slice3(0, original.Len(), original.Cap()))
+				return nil
+			}
 		}
+
 		// Make a new slice and copy each element.
 		cpy.Set(reflect.MakeSlice(original.Type(), original.Len(), original.Cap()))
+		if original.Cap() > 0 {
+			s.visited[key] = cpy
+		}
+
+		for i := 0; i < original.Len(); i++ {
+			if err := s.copyRecursive(original.Index(i), cpy.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Array:
 		for i := 0; i < original.Len(); i++ {
-			copyRecursive(original.Index(i), cpy.Index(i))
+			if err := s.copyRecursive(original.Index(i), cpy.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Chan, reflect.Func:
+		switch {
+		case original.IsNil(), s.uncopyable == NilUncopyable:
+			cpy.Set(reflect.Zero(cpy.Type()))
+		case s.uncopyable == RejectUncopyable:
+			return &UncopyableError{Type: original.Type()}
+		default:
+			cpy.Set(original)
 		}
 
 	case reflect.Map:
 		if original.IsNil() {
-			return
+			cpy.Set(reflect.Zero(cpy.Type()))
+			return nil
 		}
+
+		visitKey := visit{original.Pointer(), original.Type()}
+		if seen, found := s.visited[visitKey]; found {
+			cpy.Set(seen)
+			return nil
+		}
+
 		cpy.Set(reflect.MakeMap(original.Type()))
+		s.visited[visitKey] = cpy
 		for _, key := range original.MapKeys() {
 			originalValue := original.MapIndex(key)
 			copyValue := reflect.New(originalValue.Type()).Elem()
-			copyRecursive(originalValue, copyValue)
-			copyKey := Copy(key.Interface())
-			cpy.SetMapIndex(reflect.ValueOf(copyKey), copyValue)
+			if err := s.copyRecursive(originalValue, copyValue); err != nil {
+				return err
+			}
+			copyKey := reflect.New(key.Type()).Elem()
+			if err := s.copyRecursive(key, copyKey); err != nil {
+				return err
+			}
+			cpy.SetMapIndex(copyKey, copyValue)
 		}
 
 	default:
 		cpy.Set(original)
 	}
+
+	return nil
+}
+
+// exposeField makes the unexported field v, which must be addressable, accessible as if it were
+// exported.
+func exposeField(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
 }
@@ -0,0 +1,209 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+type node struct {
+	Name     string
+	Parent   *node
+	Children []*node
+	Next     *node
+	Prev     *node
+}
+
+func TestCopyCycles(t *testing.T) {
+	root := &node{Name: "root"}
+	childA := &node{Name: "A", Parent: root}
+	childB := &node{Name: "B", Parent: root, Prev: childA}
+	childA.Next = childB
+	root.Children = []*node{childA, childB}
+
+	cpy := deepcopy.Copy(root).(*node)
+	assert.Assert(t, cpy != root)
+	assert.Equal(t, cpy.Name, "root")
+	assert.Equal(t, len(cpy.Children), 2)
+
+	a, b := cpy.Children[0], cpy.Children[1]
+	assert.Assert(t, a != childA && b != childB)
+	assert.Assert(t, a.Parent == cpy)
+	assert.Assert(t, b.Parent == cpy)
+	assert.Assert(t, a.Next == b)
+	assert.Assert(t, b.Prev == a)
+}
+
+type sharedReferences struct {
+	PtrA   *simpleStruct
+	PtrB   *simpleStruct
+	MapA   map[string]int
+	MapB   map[string]int
+	SliceA []int
+	SliceB []int
+	SliceC []int
+}
+
+func TestCopySharedReferences(t *testing.T) {
+	ptr := &simpleStruct{FieldA: "A"}
+	m := map[string]int{"A": 1}
+	s := []int{1, 2, 3}
+	src := sharedReferences{
+		PtrA:   ptr,
+		PtrB:   ptr,
+		MapA:   m,
+		MapB:   m,
+		SliceA: s,
+		SliceB: s[:2],
+		SliceC: s[1:],
+	}
+
+	cpy := deepcopy.Copy(src).(sharedReferences)
+	assert.Assert(t, cpy.PtrA != ptr)
+	assert.Assert(t, cpy.PtrA == cpy.PtrB)
+
+	cpy.MapA["B"] = 2
+	assert.Equal(t, len(cpy.MapB), 2)
+	assert.Equal(t, len(m), 1)
+
+	cpy.SliceA[0] = 10
+	assert.Equal(t, cpy.SliceB[0], 10)
+	assert.Equal(t, s[0], 1)
+	assert.DeepEqual(t, cpy.SliceC, []int{2, 3})
+}
+
+type structWithTags struct {
+	Name   string
+	Cache  map[string]string `deepcopy:"-"`
+	Lookup map[string]int    `deepcopy:"shallow"`
+	Dirty  *bool             `deepcopy:"zero"`
+}
+
+func TestCopyWithTags(t *testing.T) {
+	dirty := true
+	src := structWithTags{
+		Name:   "A",
+		Cache:  map[string]string{"A": "B"},
+		Lookup: map[string]int{"A": 1},
+		Dirty:  &dirty,
+	}
+
+	cpy := deepcopy.Copy(src).(structWithTags)
+	assert.Equal(t, cpy.Name, src.Name)
+	assert.Assert(t, cpy.Cache == nil)
+	assert.Assert(t, cpy.Dirty == nil)
+	cpy.Lookup["B"] = 2
+	assert.Equal(t, src.Lookup["B"], 2)
+
+	var dst structWithTags
+	assert.Assert(t, !deepcopy.Partial(&dst, &src, "Cache", "Dirty"))
+	assert.Assert(t, deepcopy.Partial(&dst, &src, "Cache", "Lookup"))
+	assert.Assert(t, dst.Cache == nil)
+	assert.Equal(t, dst.Lookup["A"], 1)
+
+	dst = structWithTags{Cache: map[string]string{"C": "D"}, Dirty: &dirty}
+	assert.Assert(t, deepcopy.OnChange(&dst, &src, "Cache", "Dirty"))
+	assert.Equal(t, dst.Cache["C"], "D")
+	assert.Assert(t, dst.Dirty == nil)
+	assert.Assert(t, !deepcopy.OnChange(&dst, &src, "Cache", "Dirty"))
+
+	dst = structWithTags{Name: "B", Dirty: &dirty}
+	copied, err := deepcopy.PartialWith(&dst, &src, []string{"Dirty"}, deepcopy.WithMerge())
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.Equal(t, dst.Name, "B")
+	assert.Assert(t, dst.Dirty == nil)
+}
+
+type structWithUnexported struct {
+	Name    string
+	count   int
+	labels  map[string]string
+	parent  *structWithUnexported
+	ignored []int `deepcopy:"-"`
+}
+
+func TestCopyUnexportedFields(t *testing.T) {
+	n := big.NewInt(0).Lsh(big.NewInt(1), 100)
+	cpy, err := deepcopy.CopyWithOptions(n, deepcopy.IncludeUnexported())
+	assert.NilError(t, err)
+	nCopy := cpy.(*big.Int)
+	assert.Equal(t, nCopy.Cmp(n), 0)
+	nCopy.Add(nCopy, big.NewInt(1))
+	assert.Equal(t, n.Bit(0), uint(0))
+	assert.Equal(t, deepcopy.Copy(n).(*big.Int).Sign(), 0)
+
+	var b strings.Builder
+	b.WriteString("A")
+	cpy, err = deepcopy.CopyWithOptions(&b, deepcopy.IncludeUnexported())
+	assert.NilError(t, err)
+	bCopy := cpy.(*strings.Builder)
+	bCopy.WriteString("B")
+	assert.Equal(t, b.String(), "A")
+	assert.Equal(t, bCopy.String(), "AB")
+
+	src := structWithUnexported{Name: "A", count: 1, labels: map[string]string{"A": "B"}, ignored: []int{1}}
+	src.parent = &src
+	cpy, err = deepcopy.CopyWithOptions(src, deepcopy.IncludeUnexported())
+	assert.NilError(t, err)
+	sCopy := cpy.(structWithUnexported)
+	assert.Equal(t, sCopy.count, 1)
+	assert.DeepEqual(t, sCopy.labels, src.labels)
+	sCopy.labels["A"] = "C"
+	assert.Equal(t, src.labels["A"], "B")
+	assert.Assert(t, sCopy.parent != &src)
+	assert.Assert(t, sCopy.parent.parent == sCopy.parent)
+	assert.Assert(t, sCopy.ignored == nil)
+}
+
+type structWithUncopyable struct {
+	Nodes    [2]*node
+	Matrix   [2][]int
+	Events   chan string
+	Callback func() string
+	Nil      func()
+}
+
+func TestCopyArrays(t *testing.T) {
+	src := structWithUncopyable{
+		Nodes:  [2]*node{{Name: "A"}, {Name: "B"}},
+		Matrix: [2][]int{{1}, {2}},
+	}
+	src.Nodes[0].Next = src.Nodes[1]
+
+	cpy := deepcopy.Copy(src).(structWithUncopyable)
+	assert.Assert(t, cpy.Nodes[0] != src.Nodes[0])
+	assert.Equal(t, cpy.Nodes[1].Name, "B")
+	assert.Assert(t, cpy.Nodes[0].Next == cpy.Nodes[1])
+	cpy.Matrix[1][0] = 3
+	assert.Equal(t, src.Matrix[1][0], 2)
+
+	arr := [3]int{1, 2, 3}
+	assert.Equal(t, deepcopy.Clone(arr), arr)
+}
+
+func TestCopyChannelsAndFunctions(t *testing.T) {
+	src := structWithUncopyable{
+		Events:   make(chan string),
+		Callback: func() string { return "A" },
+	}
+
+	cpy := deepcopy.Copy(src).(structWithUncopyable)
+	assert.Equal(t, cpy.Events, src.Events)
+	assert.Equal(t, cpy.Callback(), "A")
+	assert.Assert(t, cpy.Nil == nil)
+
+	copied, err := deepcopy.CopyWithOptions(src, deepcopy.WithUncopyablePolicy(deepcopy.NilUncopyable))
+	assert.NilError(t, err)
+	cpy = copied.(structWithUncopyable)
+	assert.Assert(t, cpy.Events == nil)
+	assert.Assert(t, cpy.Callback == nil)
+
+	_, err = deepcopy.CopyWithOptions(src, deepcopy.WithUncopyablePolicy(deepcopy.RejectUncopyable))
+	assert.ErrorContains(t, err, "chan string")
+
+	_, err = deepcopy.CopyWithOptions(structWithUncopyable{}, deepcopy.WithUncopyablePolicy(deepcopy.RejectUncopyable))
+	assert.NilError(t, err)
+}
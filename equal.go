@@ -0,0 +1,424 @@
+package deepcopy
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EqualOption configures how Equal compares values.
+type EqualOption func(*equalOptions)
+
+type equalOptions struct {
+	nilEqualsEmpty bool
+	floatTolerance float64
+	ignoredPaths   []string
+	typeEquals     map[reflect.Type]EqualFunc
+	pathEquals     []pathEqual
+	// rules is the tree parsed from ignoredPaths and pathEquals, in which values at selected nodes
+	// are ignored and values at nodes with comparators are compared by them.
+	rules    tree
+	resolver NameResolver
+	copier   *Copier
+	// unexported is true if unexported fields are compared as well.
+	unexported bool
+}
+
+type pathEqual struct {
+	path  string
+	equal EqualFunc
+}
+
+func newEqualOptions(opts []EqualOption) (*equalOptions, error) {
+	o := &equalOptions{copier: defaultCopier}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	rules, err := fieldsToTree(o.ignoredPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pe := range o.pathEquals {
+		segments, err := parsePath(pe.path)
+		if err != nil {
+			return nil, err
+		}
+
+		rules.setEqual(segments, pe.equal)
+	}
+
+	o.rules = rules
+	return o, nil
+}
+
+// EqualFunc tells whether a and b of the same type are equal.
+type EqualFunc func(a, b reflect.Value) bool
+
+// NilEqualsEmpty makes nil slices and maps equal to empty ones.
+func NilEqualsEmpty() EqualOption {
+	return func(o *equalOptions) {
+		o.nilEqualsEmpty = true
+	}
+}
+
+// IgnorePaths makes values at paths never different. Paths are field paths of the values
+// compared, in which negative indexes are not supported by OnChange.
+func IgnorePaths(paths ...string) EqualOption {
+	return func(o *equalOptions) {
+		o.ignoredPaths = append(o.ignoredPaths, paths...)
+	}
+}
+
+// CompareType makes values of typ compared by equal instead of field by field, e.g. for types
+// with semantic equality like resource.Quantity.
+func CompareType(typ reflect.Type, equal EqualFunc) EqualOption {
+	return func(o *equalOptions) {
+		if o.typeEquals == nil {
+			o.typeEquals = make(map[reflect.Type]EqualFunc)
+		}
+
+		o.typeEquals[typ] = equal
+	}
+}
+
+// CompareTypeFor is like CompareType but for values of type T.
+func CompareTypeFor[T any](equal func(a, b T) bool) EqualOption {
+	return CompareType(reflect.TypeOf((*T)(nil)).Elem(), func(a, b reflect.Value) bool {
+		return equal(a.Interface().(T), b.Interface().(T))
+	})
+}
+
+// ComparePath makes values at path compared by equal, which takes precedence over comparators
+// of their types.
+func ComparePath(path string, equal EqualFunc) EqualOption {
+	return func(o *equalOptions) {
+		o.pathEquals = append(o.pathEquals, pathEqual{path: path, equal: equal})
+	}
+}
+
+// CompareUnexported makes unexported fields compared as well as exported ones, which are read via
+// unsafe. OnChange compares them if IncludeUnexported is given.
+func CompareUnexported() EqualOption {
+	return func(o *equalOptions) {
+		o.unexported = true
+	}
+}
+
+// FloatTolerance makes floating-point numbers, as well as real and imaginary parts of complex
+// numbers, equal if their difference is within tolerance.
+func FloatTolerance(tolerance float64) EqualOption {
+	return func(o *equalOptions) {
+		o.floatTolerance = tolerance
+	}
+}
+
+// Equal tells whether a and b are deeply equal in the way they are copied, which means
+//   - unexported fields, unless CompareUnexported is given, and fields tagged `deepcopy:"-"` or
+//     `deepcopy:"zero"` are not compared,
+//   - values of types with copiers registered globally or implementing Interface are compared
+//     via reflect.DeepEqual as a whole, unless comparators are given via CompareType,
+//   - pointers are equal if they point to equal values, and cycles are handled,
+//   - NaNs are equal to each other.
+//
+// It panics if any path in IgnorePaths or ComparePath is malformed.
+func Equal(a, b interface{}, opts ...EqualOption) bool {
+	o, err := newEqualOptions(opts)
+	if err != nil {
+		panic(err)
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return va.IsValid() == vb.IsValid()
+	}
+
+	if va.Type() != vb.Type() {
+		return false
+	}
+
+	return newEqualState(o).equal(va, vb, &o.rules)
+}
+
+// comparison identifies a pair of pointers, maps or slices being compared.
+type comparison struct {
+	a, b uintptr
+	typ  reflect.Type
+}
+
+// equalState keeps track of pairs compared so far in order to terminate on cycles.
+type equalState struct {
+	o       *equalOptions
+	visited map[comparison]bool
+}
+
+func newEqualState(o *equalOptions) *equalState {
+	return &equalState{o: o, visited: make(map[comparison]bool)}
+}
+
+// equal compares a and b of the same type according to rules, which is nil if there are no
+// rules for them.
+func (s *equalState) equal(a, b reflect.Value, rules *tree) bool {
+	if rules != nil && rules.selected {
+		return true
+	}
+
+	if rules != nil && rules.equal != nil {
+		return rules.equal(a, b)
+	}
+
+	typ := a.Type()
+	if equal, found := s.o.typeEquals[typ]; found {
+		return equal(a, b)
+	}
+
+	if _, found := s.o.copier.lookup(typ); found || planOf(typ).copier {
+		// Values copied as a whole are compared as a whole.
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if a.IsNil() || b.IsNil() {
+			if a.Kind() != reflect.Ptr && s.o.nilEqualsEmpty {
+				return a.Len() == 0 && b.Len() == 0
+			}
+
+			return a.IsNil() && b.IsNil()
+		}
+
+		if a.Pointer() == b.Pointer() && (rules == nil || len(rules.branches) == 0) &&
+			(a.Kind() != reflect.Slice || a.Len() == b.Len()) {
+			return true
+		}
+
+		key := comparison{a.Pointer(), b.Pointer(), typ}
+		if s.visited[key] {
+			return true
+		}
+
+		s.visited[key] = true
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr:
+		return s.equal(a.Elem(), b.Elem(), rules)
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() && b.IsNil()
+		}
+
+		if a.Elem().Type() != b.Elem().Type() {
+			return false
+		}
+
+		return s.equal(a.Elem(), b.Elem(), rules)
+	case reflect.Struct:
+		fieldRules := s.fieldRules(typ, rules)
+		fields := planOf(typ).fields
+		if s.o.unexported {
+			fields = planOf(typ).allFields
+			// Unexported fields are read via their addresses.
+			a, b = addressable(a), addressable(b)
+		}
+
+		for _, f := range fields {
+			if f.mode == copySkip || f.mode == copyZero {
+				continue
+			}
+
+			i := f.index[0]
+			fieldA, fieldB := a.Field(i), b.Field(i)
+			if f.unexported {
+				fieldA, fieldB = exposeField(fieldA), exposeField(fieldB)
+			}
+
+			if !s.equal(fieldA, fieldB, fieldRules[i]) {
+				return false
+			}
+		}
+
+		return true
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+
+		for j := 0; j < a.Len(); j++ {
+			var sub *tree
+			if rules != nil {
+				if elem, found := rules.elementTree(j, a.Len()); found {
+					sub = &elem
+				}
+			}
+
+			if !s.equal(a.Index(j), b.Index(j), sub) {
+				return false
+			}
+		}
+
+		return true
+	case reflect.Map:
+		keyRules := s.keyRules(typ, rules)
+		n := 0
+		for _, key := range a.MapKeys() {
+			sub := keyRules[key.Interface()]
+			if sub != nil && sub.selected {
+				continue
+			}
+
+			n++
+			vb := b.MapIndex(key)
+			if !vb.IsValid() || !s.equal(a.MapIndex(key), vb, sub) {
+				return false
+			}
+		}
+
+		for _, key := range b.MapKeys() {
+			if sub := keyRules[key.Interface()]; sub == nil || !sub.selected {
+				n--
+			}
+		}
+
+		return n == 0
+	case reflect.Float32, reflect.Float64:
+		return s.equalFloats(a.Float(), b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		ca, cb := a.Complex(), b.Complex()
+		return s.equalFloats(real(ca), real(cb)) && s.equalFloats(imag(ca), imag(cb))
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Func:
+		// Like reflect.DeepEqual, functions are only equal if both are nil.
+		return a.IsNil() && b.IsNil()
+	default:
+		return a.Pointer() == b.Pointer()
+	}
+}
+
+// addressable returns v if it is addressable, or an addressable copy of it otherwise.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+
+	cpy := reflect.New(v.Type()).Elem()
+	cpy.Set(v)
+	return cpy
+}
+
+func (s *equalState) equalFloats(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+
+	return a == b || math.Abs(a-b) <= s.o.floatTolerance
+}
+
+// fieldRules maps indexes of fields of the structure typ to the branches of rules applied to
+// them. Branches of promoted fields are applied to the embedded fields they are promoted from.
+func (s *equalState) fieldRules(typ reflect.Type, rules *tree) map[int]*tree {
+	if rules == nil || len(rules.branches) == 0 {
+		return nil
+	}
+
+	fields := make(map[int]*tree, len(rules.branches))
+	promoted := make(map[int]bool)
+	for name, branch := range rules.branches {
+		f := planOf(typ).field(typ, name, s.o.resolver)
+		if f == nil {
+			continue
+		}
+
+		b := branch
+		i := f.index[0]
+		switch {
+		case len(f.index) == 1:
+			fields[i] = &b
+		case fields[i] == nil:
+			embedded := newTree(rules.layer + 1)
+			embedded.branches[name] = b
+			fields[i] = &embedded
+			promoted[i] = true
+		case promoted[i]:
+			fields[i].branches[name] = b
+		}
+	}
+
+	return fields
+}
+
+// keyRules maps keys of the map typ to the branches of rules applied to them.
+func (s *equalState) keyRules(typ reflect.Type, rules *tree) map[interface{}]*tree {
+	if rules == nil || len(rules.branches) == 0 {
+		return nil
+	}
+
+	keys := make(map[interface{}]*tree, len(rules.branches))
+	for _, branch := range rules.branches {
+		if key, ok := branch.mapKey(typ); ok {
+			b := branch
+			keys[key.Interface()] = &b
+		}
+	}
+
+	return keys
+}
+
+// setEqual sets equal onto the branch at the end of segments, which are grown if absent.
+func (t tree) setEqual(segments []segment, equal EqualFunc) {
+	key := segments[0].key()
+	branch := t.FindBranch(key)
+	if branch == nil {
+		branch = t.AddBranch(segments[0])
+	}
+
+	if len(segments) == 1 {
+		branch.equal = equal
+		t.branches[key] = *branch
+		return
+	}
+
+	branch.setEqual(segments[1:], equal)
+}
+
+// descend returns the subtree at the path made of keys, in which slice elements are selected
+// by their indexes. found is false if nothing at the path is in the tree.
+func (t tree) descend(keys []string) (sub tree, found bool) {
+	sub = t
+	for _, key := range keys {
+		if sub.selected {
+			break
+		}
+
+		if strings.HasPrefix(key, "[") && !strings.HasPrefix(key, `["`) {
+			index, err := strconv.Atoi(strings.Trim(key, "[]"))
+			if err != nil {
+				return tree{}, false
+			}
+
+			if sub, found = sub.elementTree(index, 0); !found {
+				return
+			}
+
+			continue
+		}
+
+		branch := sub.FindBranch(key)
+		if branch == nil {
+			return tree{}, false
+		}
+
+		sub = *branch
+	}
+
+	return sub, true
+}
@@ -0,0 +1,188 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type comparedObject struct {
+	Name     string
+	Replicas *int
+	Labels   map[string]string
+	Items    []simpleStruct
+	Value    interface{}
+	Ratio    float64
+	Updated  time.Time
+	Cache    map[string]string `deepcopy:"-"`
+	revision int
+}
+
+func TestEqual(t *testing.T) {
+	one, anotherOne, two := 1, 1, 2
+	now := time.Now()
+	a := comparedObject{
+		Name:     "A",
+		Replicas: &one,
+		Labels:   map[string]string{"app": "A"},
+		Items:    []simpleStruct{{FieldA: "A"}},
+		Value:    []int{1},
+		Ratio:    0.3,
+		Updated:  now,
+		Cache:    map[string]string{"A": "B"},
+		revision: 1,
+	}
+	b := deepcopy.Clone(a)
+	b.Replicas = &anotherOne
+	b.revision = 2
+	assert.Assert(t, deepcopy.Equal(a, b))
+	assert.Assert(t, deepcopy.Equal(&a, &b))
+
+	b.Value = []string{"1"}
+	assert.Assert(t, !deepcopy.Equal(a, b))
+	b.Value = map[string]interface{}{"A": []int{1}}
+	a.Value = map[string]interface{}{"A": []int{1}}
+	assert.Assert(t, deepcopy.Equal(a, b))
+
+	b.Replicas = &two
+	assert.Assert(t, !deepcopy.Equal(a, b))
+	assert.Assert(t, deepcopy.Equal(a, b, deepcopy.IgnorePaths("Replicas")))
+	b.Replicas = &one
+
+	b.Updated = now.Add(time.Second)
+	assert.Assert(t, !deepcopy.Equal(a, b))
+	b.Updated = now
+
+	tenth := 0.1
+	b.Ratio = tenth + 0.2
+	assert.Assert(t, !deepcopy.Equal(a, b))
+	assert.Assert(t, deepcopy.Equal(a, b, deepcopy.FloatTolerance(1e-9)))
+	assert.Assert(t, deepcopy.Equal(math.NaN(), math.NaN()))
+
+	a.Items, b.Items = nil, []simpleStruct{}
+	a.Labels, b.Labels = map[string]string{}, nil
+	assert.Assert(t, !deepcopy.Equal(a, b, deepcopy.FloatTolerance(1e-9)))
+	assert.Assert(t, deepcopy.Equal(a, b, deepcopy.FloatTolerance(1e-9), deepcopy.NilEqualsEmpty()))
+
+	a.Items = []simpleStruct{{FieldA: "A", FieldB: 1}, {FieldA: "B", FieldB: 2}}
+	b.Items = []simpleStruct{{FieldA: "A", FieldB: 3}, {FieldA: "B", FieldB: 4}}
+	a.Labels = map[string]string{"app": "A", "version": "1"}
+	b.Labels = map[string]string{"app": "A", "revision": "2"}
+	assert.Assert(t, deepcopy.Equal(a, b, deepcopy.FloatTolerance(1e-9),
+		deepcopy.IgnorePaths("Items.FieldB", "Labels.version", "Labels.revision")))
+	assert.Assert(t, !deepcopy.Equal(a, b, deepcopy.FloatTolerance(1e-9),
+		deepcopy.IgnorePaths("Items[0].FieldB", "Labels.version", "Labels.revision")))
+
+	cycleA, cycleB := &node{Name: "A"}, &node{Name: "A"}
+	cycleA.Next, cycleB.Next = cycleA, cycleB
+	assert.Assert(t, deepcopy.Equal(cycleA, cycleB))
+	assert.Assert(t, !deepcopy.Equal(cycleA, node{Name: "A"}))
+	assert.Assert(t, deepcopy.Equal(nil, nil))
+	assert.Assert(t, !deepcopy.Equal(nil, cycleA))
+}
+
+func TestOnChangeWithEqualOptions(t *testing.T) {
+	src := comparedObject{Name: "A", Ratio: 0.3, Items: []simpleStruct{}, Labels: map[string]string{"app": "A"}}
+	tenth := 0.1
+	dst := comparedObject{Name: "A", Ratio: tenth + 0.2, Labels: map[string]string{"app": "B"}}
+
+	copied, err := deepcopy.OnChangeWith(&dst, &src, []string{"Ratio", "Items", "Labels"},
+		deepcopy.WithEqualOptions(deepcopy.FloatTolerance(1e-9), deepcopy.NilEqualsEmpty(),
+			deepcopy.IgnorePaths("Labels.app")))
+	assert.NilError(t, err)
+	assert.Assert(t, !copied)
+	assert.Equal(t, dst.Labels["app"], "B")
+
+	copied, err = deepcopy.OnChangeWith(&dst, &src, []string{"Ratio", "Items", "Labels"})
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.DeepEqual(t, dst.Labels, src.Labels)
+
+	dst.Value, src.Value = []int{1}, []interface{}{func() {}}
+	assert.Assert(t, deepcopy.OnChange(&dst, &src, "Value"))
+
+	_, err = deepcopy.OnChangeWith(&dst, &src, []string{"Name"}, deepcopy.WithEqualOptions(deepcopy.IgnorePaths("[")))
+	assert.ErrorContains(t, err, "unclosed bracket")
+}
+
+type bigObject struct {
+	Name  string
+	Count big.Int
+}
+
+func TestEqualOfUnexportedFields(t *testing.T) {
+	assert.Assert(t, deepcopy.Equal(big.NewInt(1), big.NewInt(2)))
+	assert.Assert(t, !deepcopy.Equal(big.NewInt(1), big.NewInt(2), deepcopy.CompareUnexported()))
+	assert.Assert(t, deepcopy.Equal(*big.NewInt(2), *big.NewInt(2), deepcopy.CompareUnexported()))
+
+	src, dst := bigObject{Name: "A"}, bigObject{Name: "A"}
+	src.Count.SetInt64(2)
+	dst.Count.SetInt64(1)
+	copied, err := deepcopy.OnChangeWith(&dst, &src, []string{"Count"})
+	assert.NilError(t, err)
+	assert.Assert(t, !copied)
+
+	changes, err := deepcopy.OnChangeReportWith(&dst, &src, []string{"Count"}, deepcopy.IncludeUnexported())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"Count"})
+	assert.Equal(t, dst.Count.Int64(), int64(2))
+}
+
+type quantity struct {
+	Amount string
+}
+
+func (q quantity) millis() int64 {
+	if strings.HasSuffix(q.Amount, "m") {
+		n, _ := strconv.ParseInt(strings.TrimSuffix(q.Amount, "m"), 10, 64)
+		return n
+	}
+
+	n, _ := strconv.ParseInt(q.Amount, 10, 64)
+	return n * 1000
+}
+
+type resources struct {
+	CPU    quantity
+	Limits map[string]quantity
+	Owner  string
+}
+
+func TestComparators(t *testing.T) {
+	sameMillis := deepcopy.CompareTypeFor(func(a, b quantity) bool {
+		return a.millis() == b.millis()
+	})
+	sameOwner := deepcopy.ComparePath("Owner", func(a, b reflect.Value) bool {
+		return strings.EqualFold(a.String(), b.String())
+	})
+
+	a := resources{CPU: quantity{"1"}, Limits: map[string]quantity{"cpu": {"2"}}, Owner: "Alice"}
+	b := resources{CPU: quantity{"1000m"}, Limits: map[string]quantity{"cpu": {"2000m"}}, Owner: "alice"}
+	assert.Assert(t, !deepcopy.Equal(a, b))
+	assert.Assert(t, !deepcopy.Equal(a, b, sameMillis))
+	assert.Assert(t, deepcopy.Equal(a, b, sameMillis, sameOwner))
+	assert.Assert(t, deepcopy.Equal(&a, &b, sameMillis, sameOwner))
+
+	never := deepcopy.ComparePath("Limits.cpu", func(a, b reflect.Value) bool { return false })
+	assert.Assert(t, !deepcopy.Equal(a, b, sameMillis, sameOwner, never))
+
+	dst := b
+	copied, err := deepcopy.OnChangeWith(&dst, &a, []string{"CPU", "Limits", "Owner"},
+		deepcopy.WithEqualOptions(sameMillis, sameOwner))
+	assert.NilError(t, err)
+	assert.Assert(t, !copied)
+	assert.DeepEqual(t, dst, b)
+
+	changes, err := deepcopy.OnChangeReportWith(&dst, &a, []string{"CPU", "Limits.cpu", "Owner"},
+		deepcopy.WithEqualOptions(sameMillis))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"Owner"})
+	assert.Equal(t, dst.CPU.Amount, "1000m")
+	assert.Equal(t, dst.Owner, "Alice")
+}
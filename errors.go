@@ -0,0 +1,77 @@
+package deepcopy
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrNilDestination is returned when the destination is nil or isn't a pointer.
+var ErrNilDestination = errors.New("the destination must be a non-nil pointer")
+
+// ErrVetoChange is returned by FieldHooks to veto changes. It can also be wrapped.
+var ErrVetoChange = errors.New("the change is vetoed")
+
+// PathError reports a field path which can't be used to select fields.
+// Index is the position of the offending segment in Path.
+type PathError struct {
+	Path   string
+	Index  int
+	Reason string
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("field path %q %s at index %d", e.Path, e.Reason, e.Index)
+}
+
+// PathErrors collects all the field paths which can't be resolved against a type.
+type PathErrors []*PathError
+
+func (e PathErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// KindError reports an object which is neither a pointer, a structure, a slice nor a map.
+type KindError struct {
+	Kind reflect.Kind
+}
+
+func (e *KindError) Error() string {
+	return fmt.Sprintf("the object should be a pointer, structure, slice or map but %s", e.Kind)
+}
+
+// TypeMismatchError reports a source and a destination which are of different types.
+type TypeMismatchError struct {
+	Src reflect.Type
+	Dst reflect.Type
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("both src and dst must have the same type but %s and %s", e.Src, e.Dst)
+}
+
+// UncopyableError reports a channel or function which can't be copied under RejectUncopyable.
+type UncopyableError struct {
+	Type reflect.Type
+}
+
+func (e *UncopyableError) Error() string {
+	return fmt.Sprintf("values of %s can't be copied", e.Type)
+}
+
+// PatchError reports a patch operation which can't be applied.
+type PatchError struct {
+	Op     string
+	Path   string
+	Reason string
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("patch operation %q at %q %s", e.Op, e.Path, e.Reason)
+}
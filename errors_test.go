@@ -0,0 +1,64 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"testing"
+)
+
+type copyAsString struct {
+	Value string
+}
+
+func (c copyAsString) DeepCopy() interface{} {
+	return c.Value
+}
+
+func TestMalformedFieldPaths(t *testing.T) {
+	src := simpleStruct{FieldA: "FieldA"}
+	var dst simpleStruct
+
+	_, err := deepcopy.PartialE(&dst, &src, "FieldA", "FieldB..FieldC")
+	pathErr, ok := err.(*deepcopy.PathError)
+	assert.Assert(t, ok, "%#v", err)
+	assert.Equal(t, pathErr.Path, "FieldB..FieldC")
+	assert.Equal(t, pathErr.Index, 1)
+
+	_, err = deepcopy.OnChangeE(&dst, &src, "")
+	pathErr, ok = err.(*deepcopy.PathError)
+	assert.Assert(t, ok, "%#v", err)
+	assert.Equal(t, pathErr.Index, 0)
+
+	_, err = deepcopy.NewPartialReplicatorE("FieldA.")
+	assert.Assert(t, err != nil)
+	assert.Equal(t, dst.FieldA, "")
+}
+
+func TestMismatchedObjects(t *testing.T) {
+	src := simpleStruct{FieldA: "FieldA"}
+	other := structWithSliceOfPointers{}
+
+	_, err := deepcopy.PartialE(&other, &src, "FieldA")
+	mismatch, ok := err.(*deepcopy.TypeMismatchError)
+	assert.Assert(t, ok, "%#v", err)
+	assert.Equal(t, mismatch.Src.String(), "*deepcopy_test.simpleStruct")
+	assert.Equal(t, mismatch.Dst.String(), "*deepcopy_test.structWithSliceOfPointers")
+
+	_, err = deepcopy.OnChangeE(nil, &src, "FieldA")
+	assert.Equal(t, err, deepcopy.ErrNilDestination)
+
+	var dst simpleStruct
+	_, err = deepcopy.NewPartialReplicator("FieldA").CopyE(dst, src)
+	assert.Equal(t, err, deepcopy.ErrNilDestination)
+
+	var copied bool
+	copied, err = deepcopy.PartialE(&src.FieldA, &src.FieldA, "FieldA")
+	assert.Assert(t, !copied)
+	_, ok = err.(*deepcopy.KindError)
+	assert.Assert(t, ok, "%#v", err)
+	assert.Error(t, err, "the object should be a pointer, structure, slice or map but string")
+
+	_, err = deepcopy.CopyE(copyAsString{Value: "A"})
+	_, ok = err.(*deepcopy.TypeMismatchError)
+	assert.Assert(t, ok, "%#v", err)
+}
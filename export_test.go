@@ -0,0 +1,10 @@
+package deepcopy
+
+// ResetPlans drops all the copy plans cached, so that benchmarks can measure copies of types never
+// copied before.
+func ResetPlans() {
+	plans.Range(func(typ, _ interface{}) bool {
+		plans.Delete(typ)
+		return true
+	})
+}
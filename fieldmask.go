@@ -0,0 +1,100 @@
+package deepcopy
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FieldMask is the selection of fields made by paths of a google.protobuf.FieldMask, e.g.
+// "spec.min_ready_seconds". A path selects the whole field it ends with, including all elements
+// of repeated fields and all entries of maps.
+type FieldMask struct {
+	paths    []string
+	resolver NameResolver
+}
+
+// FromFieldMask parses paths of a google.protobuf.FieldMask, whose segments are names resolved
+// by resolver, or by ProtobufTagNames if resolver is nil, so that names in protobuf tags of
+// generated Go structures are selected. A mask without paths selects nothing.
+func FromFieldMask(paths []string, resolver NameResolver) (*FieldMask, error) {
+	for _, path := range paths {
+		for i, name := range strings.Split(path, ".") {
+			if err := checkFieldMaskName(path, i, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if resolver == nil {
+		resolver = ProtobufTagNames
+	}
+
+	return &FieldMask{paths: append([]string(nil), paths...), resolver: ownCache(resolver)}, nil
+}
+
+// checkFieldMaskName checks the name at index in path, which can only be made of letters, digits
+// and underscores.
+func checkFieldMaskName(path string, index int, name string) error {
+	if len(name) == 0 {
+		return &PathError{Path: path, Index: index, Reason: "contains a blank segment"}
+	}
+
+	for _, c := range name {
+		if c != '_' && (c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return &PathError{Path: path, Index: index, Reason: "contains an invalid name " + name}
+		}
+	}
+
+	return nil
+}
+
+// tree returns the tree of fields selected. A new one is returned every time since trees are
+// changed by options like WithMergeKeys.
+func (m *FieldMask) tree() tree {
+	hierarchy, _ := fieldsToTree(m.paths)
+	return hierarchy
+}
+
+// Validate resolves all paths of the mask against the type of prototype. Every path unknown to
+// the type is reported in a PathErrors.
+func (m *FieldMask) Validate(prototype interface{}) error {
+	typ := reflect.TypeOf(prototype)
+	if typ == nil {
+		return &KindError{Kind: reflect.Invalid}
+	}
+
+	hierarchy := m.tree()
+	if errs := validateTree(typ, &hierarchy, nil, newOptions([]Option{WithNameResolver(m.resolver)})); len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// options returns options made of opts, along with the resolver of the mask.
+func (m *FieldMask) options(opts []Option) *options {
+	o := newOptions(opts)
+	o.resolver = m.resolver
+	return o
+}
+
+// NewFieldMaskReplicator creates a replicator copying fields selected by mask, like the one
+// created by NewPartialReplicatorWith.
+func NewFieldMaskReplicator(mask *FieldMask, opts ...Option) (PartialReplicator, error) {
+	o := mask.options(opts)
+	hierarchy := mask.tree()
+	if err := applyMergeKeys(&hierarchy, o); err != nil {
+		return nil, err
+	}
+
+	return &partialReplicator{
+		hierarchy: hierarchy,
+		opts:      o,
+	}, nil
+}
+
+// OnChangeMask is like OnChangeWith but copies fields selected by mask.
+func OnChangeMask(dst, src interface{}, mask *FieldMask, opts ...Option) (copied bool, err error) {
+	rec, err := recordTreeChanges(dst, src, mask.tree(), mask.options(opts))
+	return len(rec.changes) > 0, err
+}
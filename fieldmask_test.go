@@ -0,0 +1,65 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"testing"
+)
+
+type pbObjectMeta struct {
+	Name   string            `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Labels map[string]string `protobuf:"bytes,2,rep,name=labels,proto3" json:"labels,omitempty"`
+}
+
+type pbDeploymentSpec struct {
+	Replicas        int32    `protobuf:"varint,1,opt,name=replicas,proto3" json:"replicas,omitempty"`
+	MinReadySeconds int32    `protobuf:"varint,2,opt,name=min_ready_seconds,json=minReadySeconds,proto3" json:"min_ready_seconds,omitempty"`
+	Images          []string `protobuf:"bytes,3,rep,name=images,proto3" json:"images,omitempty"`
+}
+
+type pbDeployment struct {
+	Metadata *pbObjectMeta     `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Spec     *pbDeploymentSpec `protobuf:"bytes,2,opt,name=spec,proto3" json:"spec,omitempty"`
+}
+
+func TestFieldMask(t *testing.T) {
+	src := &pbDeployment{
+		Metadata: &pbObjectMeta{Name: "app", Labels: map[string]string{"app": "A"}},
+		Spec:     &pbDeploymentSpec{Replicas: 3, MinReadySeconds: 10, Images: []string{"app:v1"}},
+	}
+
+	mask, err := deepcopy.FromFieldMask([]string{"metadata.labels", "spec.min_ready_seconds", "spec.images"}, nil)
+	assert.NilError(t, err)
+	assert.NilError(t, mask.Validate(pbDeployment{}))
+
+	r, err := deepcopy.NewFieldMaskReplicator(mask)
+	assert.NilError(t, err)
+	dst := &pbDeployment{}
+	assert.Assert(t, r.Copy(dst, src))
+	assert.DeepEqual(t, dst, &pbDeployment{
+		Metadata: &pbObjectMeta{Labels: map[string]string{"app": "A"}},
+		Spec:     &pbDeploymentSpec{MinReadySeconds: 10, Images: []string{"app:v1"}},
+	})
+
+	dst = &pbDeployment{Metadata: &pbObjectMeta{Name: "stale"}, Spec: &pbDeploymentSpec{Replicas: 1, MinReadySeconds: 10}}
+	copied, err := deepcopy.OnChangeMask(dst, src, mask)
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.DeepEqual(t, dst, &pbDeployment{
+		Metadata: &pbObjectMeta{Name: "stale", Labels: map[string]string{"app": "A"}},
+		Spec:     &pbDeploymentSpec{Replicas: 1, MinReadySeconds: 10, Images: []string{"app:v1"}},
+	})
+
+	copied, err = deepcopy.OnChangeMask(dst, src, mask)
+	assert.NilError(t, err)
+	assert.Assert(t, !copied)
+
+	mask, err = deepcopy.FromFieldMask([]string{"spec.minReadySeconds"}, deepcopy.JSONTagNames)
+	assert.NilError(t, err)
+	assert.ErrorContains(t, mask.Validate(&pbDeployment{}), `field path "spec.minReadySeconds"`)
+
+	_, err = deepcopy.FromFieldMask([]string{"spec..replicas"}, nil)
+	assert.ErrorContains(t, err, "blank segment")
+	_, err = deepcopy.FromFieldMask([]string{"spec.images[0]"}, nil)
+	assert.ErrorContains(t, err, "invalid name images[0]")
+}
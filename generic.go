@@ -0,0 +1,60 @@
+package deepcopy
+
+import "reflect"
+
+// Clone is like Copy but returns a copy of the same type as src.
+func Clone[T any](src T) T {
+	cpy, err := CloneE(src)
+	if err != nil {
+		panic(err)
+	}
+
+	return cpy
+}
+
+// CloneE is like Clone but returns an error instead of panicking, and is configured by opts.
+func CloneE[T any](src T, opts ...Option) (cpy T, err error) {
+	copied, err := CopyWithOptions(src, opts...)
+	if err != nil || copied == nil {
+		return
+	}
+
+	return copied.(T), nil
+}
+
+// PartialOf is like Partial but only accepts objects of the same type.
+func PartialOf[T any](dst, src *T, fieldsSelected ...string) (copied bool) {
+	return Partial(dst, src, fieldsSelected...)
+}
+
+// OnChangeOf is like OnChange but only accepts objects of the same type.
+func OnChangeOf[T any](dst, src *T, fieldsSelected ...string) (copied bool) {
+	return OnChange(dst, src, fieldsSelected...)
+}
+
+// Replicator is a PartialReplicator which only accepts objects of type T.
+type Replicator[T any] struct {
+	r *partialReplicator
+}
+
+// NewReplicator creates a Replicator copying fields selected. Like NewTypedPartialReplicator,
+// every field unknown to T is reported in a PathErrors.
+func NewReplicator[T any](fieldsSelected []string, opts ...Option) (*Replicator[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	r, err := newTypedPartialReplicator(typ, fieldsSelected, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	r.typ = reflect.PtrTo(typ)
+	return &Replicator[T]{r: r}, nil
+}
+
+func (r *Replicator[T]) Copy(dst, src *T) (copied bool) {
+	return r.r.Copy(dst, src)
+}
+
+// CopyE is like Copy but returns an error instead of panicking.
+func (r *Replicator[T]) CopyE(dst, src *T) (copied bool, err error) {
+	return r.r.CopyE(dst, src)
+}
@@ -0,0 +1,39 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"testing"
+)
+
+func TestGenerics(t *testing.T) {
+	src := structWithMaps{
+		Labels: map[string]string{"app": "A"},
+		Items:  map[string]simpleStruct{"A": {FieldA: "A"}},
+	}
+
+	cpy := deepcopy.Clone(src)
+	assert.DeepEqual(t, cpy, src)
+	cpy.Labels["app"] = "B"
+	assert.Equal(t, src.Labels["app"], "A")
+
+	var stringer interface{ String() string }
+	assert.Assert(t, deepcopy.Clone(stringer) == nil)
+
+	var dst structWithMaps
+	assert.Assert(t, deepcopy.PartialOf(&dst, &src, "Labels"))
+	assert.DeepEqual(t, dst.Labels, src.Labels)
+	assert.Assert(t, dst.Items == nil)
+	assert.Assert(t, deepcopy.OnChangeOf(&dst, &src, "Items.A"))
+	assert.DeepEqual(t, dst.Items, src.Items)
+
+	replicator, err := deepcopy.NewReplicator[structWithMaps]([]string{"Items.A.FieldA"})
+	assert.NilError(t, err)
+	dst = structWithMaps{}
+	assert.Assert(t, replicator.Copy(&dst, &src))
+	assert.DeepEqual(t, dst.Items, src.Items)
+
+	_, err = deepcopy.NewReplicator[structWithMaps]([]string{"Items.A.FieldD"})
+	_, ok := err.(deepcopy.PathErrors)
+	assert.Assert(t, ok)
+}
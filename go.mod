@@ -1,3 +1,3 @@
 module github.com/kitt1987/deepcopy
 
-go 1.12
+go 1.18
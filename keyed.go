@@ -0,0 +1,499 @@
+package deepcopy
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// applyMergeKeys sets merge keys configured in o onto the slices in t they are declared for.
+func applyMergeKeys(t *tree, o *options) error {
+	for _, mk := range o.mergeKeys {
+		segments, err := parsePath(mk.path)
+		if err != nil {
+			return err
+		}
+
+		var fields []segment
+		for _, seg := range segments {
+			if seg.kind != segmentIndex && seg.kind != segmentWildcard {
+				fields = append(fields, seg)
+			}
+		}
+
+		if len(fields) == 0 {
+			t.mergeKeys = mk.keys
+			continue
+		}
+
+		t.setMergeKeys(fields, mk.keys, false)
+	}
+
+	return nil
+}
+
+// setMergeKeys sets keys onto the branches at the end of segments. Branches selecting elements
+// of slices are walked through without consuming segments. Branches absent from values selected
+// as a whole, i.e. if the tree or any of its ancestors is selected, are grown to carry the keys.
+func (t tree) setMergeKeys(segments []segment, keys []string, selected bool) {
+	selected = selected || t.selected
+	if _, found := t.branches[segments[0].key()]; !found && selected {
+		t.AddBranch(segments[0])
+	}
+
+	for value, branch := range t.branches {
+		switch {
+		case branch.kind == segmentIndex || branch.kind == segmentWildcard:
+			branch.setMergeKeys(segments, keys, selected)
+		case branch.name != segments[0].name:
+		case len(segments) == 1:
+			branch.mergeKeys = keys
+			t.branches[value] = branch
+		default:
+			branch.setMergeKeys(segments[1:], keys, selected)
+		}
+	}
+}
+
+// hasKeyedBranches tells whether elements of any slice in the value the tree applied to are
+// matched by merge keys.
+func (t tree) hasKeyedBranches() bool {
+	for _, b := range t.branches {
+		if len(b.mergeKeys) > 0 || b.hasKeyedBranches() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateMergeKeys resolves paths of merge keys configured in o against typ, and reports those
+// which are not slices of structures having all the keys.
+func validateMergeKeys(typ reflect.Type, o *options) error {
+	for _, mk := range o.mergeKeys {
+		segments, err := parsePath(mk.path)
+		if err != nil {
+			return err
+		}
+
+		target := typ
+		for i, seg := range segments {
+			for target.Kind() == reflect.Ptr {
+				target = target.Elem()
+			}
+
+			fail := &PathError{Path: mk.path, Index: i}
+			switch {
+			case seg.kind == segmentIndex || seg.kind == segmentWildcard:
+				if target.Kind() != reflect.Slice {
+					fail.Reason = fmt.Sprintf("can't be applied to %s which is not a slice", target)
+					return fail
+				}
+
+				// Indexes and wildcards are ignored like they are when keys are applied.
+				continue
+			case target.Kind() == reflect.Slice:
+				target = elemType(target)
+			}
+
+			switch target.Kind() {
+			case reflect.Map:
+				if _, ok := (tree{kind: seg.kind, name: seg.name}).mapKey(target); !ok {
+					fail.Reason = fmt.Sprintf("is not a valid key of %s", target)
+					return fail
+				}
+
+				target = target.Elem()
+			case reflect.Struct:
+				field := planOf(target).field(target, seg.name, o.resolver)
+				if seg.kind != segmentField || field == nil {
+					fail.Reason = fmt.Sprintf("is not an exported field of %s", target)
+					return fail
+				}
+
+				target = target.FieldByIndex(field.index).Type
+			default:
+				fail.Reason = fmt.Sprintf("can't be traversed since %s is a %s", target, target.Kind())
+				return fail
+			}
+		}
+
+		for target.Kind() == reflect.Ptr {
+			target = target.Elem()
+		}
+
+		elem := target
+		if target.Kind() == reflect.Slice {
+			elem = elemType(target)
+		}
+
+		fail := &PathError{Path: mk.path, Index: len(segments)}
+		if target.Kind() != reflect.Slice || elem.Kind() != reflect.Struct {
+			fail.Reason = fmt.Sprintf("can't be matched by keys since %s is not a slice of structures", target)
+			return fail
+		}
+
+		for _, key := range mk.keys {
+			if planOf(elem).field(elem, key, o.resolver) == nil {
+				fail.Reason = fmt.Sprintf("can't be matched by %s which is not an exported field of %s", key, elem)
+				return fail
+			}
+		}
+	}
+
+	return nil
+}
+
+// matchesByKeys tells whether elements of the slice of typ the tree applied to are matched by
+// merge keys, so that the slice is walked through even if it is selected as a whole.
+func (t tree) matchesByKeys(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	return len(t.mergeKeys) > 0 && typ.Kind() == reflect.Slice
+}
+
+// walksThrough tells whether src selected as a whole by the tree at the path made of keys is copied
+// into dst part by part, so that elements of slices in it are matched by merge keys, and hooks of
+// values in it are called. Values absent from either side, and those compared, copied or hooked as
+// a whole, are copied as a whole.
+func (t tree) walksThrough(dst, src reflect.Value, keys []string, o *options) bool {
+	if t.matchesByKeys(src.Type()) {
+		return true
+	}
+
+	if !t.hasKeyedBranches() && !o.hooksBelow(keys) || o.hooksAt(keys) {
+		return false
+	}
+
+	for src.Kind() == reflect.Ptr {
+		if !o.divisible(keys, dst, src) {
+			return false
+		}
+
+		dst, src = dst.Elem(), src.Elem()
+	}
+
+	switch src.Kind() {
+	case reflect.Struct, reflect.Slice:
+		return o.divisible(keys, dst, src)
+	case reflect.Map:
+		_, ok := t.expandMap(dst, src)
+		return ok && o.divisible(keys, dst, src)
+	default:
+		return false
+	}
+}
+
+// expand returns the tree selecting every field of the structure typ the tree applied to as a
+// whole instead of the structure, along with branches of the tree.
+func (t tree) expand(typ reflect.Type, o *options) tree {
+	expanded := newTree(t.layer)
+	fields, _ := selectableFields(typ, o.resolver)
+	for _, name := range fields {
+		expanded.AddBranch(segment{kind: segmentField, name: name})
+		expanded.mark(name, true, false)
+	}
+
+	expanded.graft(t)
+	return expanded
+}
+
+// expandMap is like expand but selects every entry of the maps dst and src. ok is false if a key
+// of them can't be a segment of field paths.
+func (t tree) expandMap(dst, src reflect.Value) (expanded tree, ok bool) {
+	expanded = newTree(t.layer)
+	for _, m := range []reflect.Value{dst, src} {
+		for _, key := range m.MapKeys() {
+			name, ok := mapKeySegment(key)
+			if !ok {
+				return tree{}, false
+			}
+
+			segments, err := parsePath(name)
+			if err != nil || len(segments) != 1 || segments[0].key() != name {
+				return tree{}, false
+			}
+
+			expanded.AddBranch(segments[0])
+			expanded.mark(name, true, false)
+		}
+	}
+
+	expanded.graft(t)
+	return expanded, true
+}
+
+// mergeKeyOf returns the merge key of the element v of a slice, which is built from values of the
+// fields keys.
+func mergeKeyOf(v reflect.Value, keys []string, o *options) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return "", &KindError{Kind: v.Kind()}
+	}
+
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		field, _ := fieldByName(v, key, o.resolver)
+		// Keys are made of values pointed to rather than pointers, which are different in
+		// different objects.
+		for field.Kind() == reflect.Ptr && !field.IsNil() {
+			field = field.Elem()
+		}
+
+		if field.IsValid() && (field.Kind() != reflect.Ptr || !field.IsNil()) {
+			values[i] = field.Interface()
+		}
+	}
+
+	return fmt.Sprintf("%#v", values), nil
+}
+
+// copyMergeKeys copies the fields keys of the element src of a slice into the new element dst,
+// so that elements appended can be matched by their keys even if the keys aren't selected.
+func copyMergeKeys(dst, src reflect.Value, keys []string, o *options) error {
+	for src.Kind() == reflect.Ptr {
+		if src.IsNil() {
+			return nil
+		}
+
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+
+		src, dst = src.Elem(), dst.Elem()
+	}
+
+	if src.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for _, key := range keys {
+		field, _ := fieldByName(src, key, o.resolver)
+		if !field.IsValid() {
+			continue
+		}
+
+		if err := copyRecursive(field, settableField(dst, key, o.resolver), o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keyedElements indexes elements of a slice by their merge keys. Elements of the same key are
+// matched in order.
+type keyedElements map[string][]int
+
+func newKeyedElements(slice reflect.Value, keys []string, o *options) (keyedElements, error) {
+	elements := keyedElements{}
+	for i := 0; i < slice.Len(); i++ {
+		key, err := mergeKeyOf(slice.Index(i), keys, o)
+		if err != nil {
+			return nil, err
+		}
+
+		elements[key] = append(elements[key], i)
+	}
+
+	return elements, nil
+}
+
+// take returns the index of the first element of key not taken yet.
+func (e keyedElements) take(key string) (index int, found bool) {
+	indexes := e[key]
+	if len(indexes) == 0 {
+		return -1, false
+	}
+
+	e[key] = indexes[1:]
+	return indexes[0], true
+}
+
+// copyKeyedSliceChanges is like copySliceChanges but matches elements by merge keys. Elements
+// matched are kept in the order in dst, and those new to dst are appended in the order in src.
+func copyKeyedSliceChanges(dst, src reflect.Value, hierarchy *tree, tr *stackTracer, rec *changeRecorder,
+	o *options) (copied bool, err error) {
+	elements, err := newKeyedElements(dst, hierarchy.mergeKeys, o)
+	if err != nil {
+		return
+	}
+
+	if o.dryRun && dst.Len() > 0 {
+		// Elements matched are changed in place, so they are changed in a copy of dst in dry runs.
+		cloneSlice(dst, dst.Len())
+	}
+
+	all := hierarchy.selectsAllElements()
+	matched := make([]bool, dst.Len())
+	var added []reflect.Value
+	for j := 0; j < src.Len(); j++ {
+		key, keyErr := mergeKeyOf(src.Index(j), hierarchy.mergeKeys, o)
+		if keyErr != nil {
+			err = keyErr
+			return
+		}
+
+		sub, found := hierarchy.elementTree(j, src.Len())
+		i, ok := elements.take(key)
+		if !ok {
+			if all {
+				elem, elemErr := copyNewElement(src.Index(j), &sub, found, tr, o)
+				if elemErr == nil && found && !sub.excluded {
+					elemErr = copyMergeKeys(elem, src.Index(j), hierarchy.mergeKeys, o)
+				}
+
+				if elemErr != nil {
+					err = elemErr
+					return
+				}
+
+				added = append(added, elem)
+			}
+
+			continue
+		}
+
+		matched[i] = true
+		if !found || sub.excluded {
+			continue
+		}
+
+		tr.PrintfLn("The %dth element of source field【%s】matches the %dth element in destination by %s!",
+			j, tr.Prefix(), i, key)
+		rec.push("[" + strconv.Itoa(i) + "]")
+		elemCopied, elemErr := copyElementChanges(dst.Index(i), src.Index(j), &sub, tr, rec, o)
+		if elemErr != nil {
+			err = elemErr
+			return
+		}
+
+		rec.pop()
+		copied = copied || elemCopied
+	}
+
+	if !all {
+		return
+	}
+
+	var kept []reflect.Value
+	for i := 0; i < dst.Len(); i++ {
+		if !matched[i] {
+			rec.push("[" + strconv.Itoa(i) + "]")
+			err = o.allowChange(rec.keys, dst.Index(i), reflect.Value{})
+			if err == nil {
+				tr.PrintfLn("The %dth element of destination field【%s】is removed!", i, tr.Prefix())
+				rec.recordResize(dst.Index(i).Interface(), nil, resizeRemove)
+			}
+
+			rec.pop()
+			if err == nil {
+				continue
+			} else if err != ErrVetoChange {
+				return
+			}
+
+			err = nil
+		}
+
+		kept = append(kept, dst.Index(i))
+	}
+
+	var appended []reflect.Value
+	for _, elem := range added {
+		rec.push("[" + strconv.Itoa(len(kept)+len(appended)) + "]")
+		if err = o.allowChange(rec.keys, reflect.Value{}, elem); err != nil {
+			rec.pop()
+			if err == ErrVetoChange {
+				err = nil
+				break
+			}
+
+			return
+		}
+
+		tr.PrintfLn("An element of source field【%s】is appended!", tr.Prefix())
+		rec.recordResize(nil, elem.Interface(), resizeInsert)
+		rec.pop()
+		appended = append(appended, elem)
+	}
+
+	if len(kept) == dst.Len() && len(appended) == 0 {
+		return
+	}
+
+	var slice reflect.Value
+	if len(kept) == 0 && len(appended) == 0 && src.IsNil() {
+		slice = reflect.Zero(dst.Type())
+	} else {
+		slice = reflect.MakeSlice(dst.Type(), 0, len(kept)+len(appended))
+		slice = reflect.Append(slice, kept...)
+		slice = reflect.Append(slice, appended...)
+	}
+
+	dst.Set(slice)
+	copied = true
+	return
+}
+
+// mergeKeyedSlice is like mergeObject for slices but matches elements by merge keys. Elements
+// absent from dst are appended, and no elements are removed.
+func mergeKeyedSlice(dst, src reflect.Value, hierarchy *tree, o *options) (copied bool, err error) {
+	elements, err := newKeyedElements(dst, hierarchy.mergeKeys, o)
+	if err != nil {
+		return
+	}
+
+	n := dst.Len()
+	for j := 0; j < src.Len(); j++ {
+		sub, found := hierarchy.elementTree(j, src.Len())
+		if !found || sub.excluded {
+			continue
+		}
+
+		key, keyErr := mergeKeyOf(src.Index(j), hierarchy.mergeKeys, o)
+		if keyErr != nil {
+			err = keyErr
+			return
+		}
+
+		i, ok := elements.take(key)
+		if !ok {
+			if !hierarchy.selectsAllElements() {
+				continue
+			}
+
+			if dst.Len() == n {
+				// Elements are appended to a new slice in case the one in dst shares its underlying array.
+				slice := reflect.MakeSlice(dst.Type(), n, n+src.Len())
+				reflect.Copy(slice, dst)
+				dst.Set(slice)
+			}
+
+			i = dst.Len()
+			dst.Set(reflect.Append(dst, reflect.Zero(dst.Type().Elem())))
+			if err = copyMergeKeys(dst.Index(i), src.Index(j), hierarchy.mergeKeys, o); err != nil {
+				return
+			}
+		}
+
+		elemCopied, elemErr := mergeValue(dst.Index(i), src.Index(j), &sub, copyDeep, o)
+		if elemErr != nil {
+			err = elemErr
+			return
+		}
+
+		copied = copied || elemCopied
+	}
+
+	return
+}
@@ -0,0 +1,186 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"testing"
+)
+
+type keyedPort struct {
+	ContainerPort int32
+	Protocol      string
+	Name          string
+}
+
+type keyedContainer struct {
+	Name  string
+	Image string
+	Ports []keyedPort
+}
+
+type keyedPod struct {
+	Containers []keyedContainer
+}
+
+type keyedDeployment struct {
+	Replicas int
+	Spec     *keyedPod
+}
+
+func TestOnChangeWithMergeKeys(t *testing.T) {
+	src := keyedPod{Containers: []keyedContainer{
+		{Name: "sidecar", Image: "sidecar:v1"},
+		{Name: "app", Image: "app:v2", Ports: []keyedPort{
+			{ContainerPort: 80, Protocol: "UDP", Name: "dns"},
+			{ContainerPort: 80, Protocol: "TCP", Name: "http"},
+		}},
+	}}
+	dst := keyedPod{Containers: []keyedContainer{
+		{Name: "app", Image: "app:v1", Ports: []keyedPort{
+			{ContainerPort: 80, Protocol: "TCP", Name: "web"},
+			{ContainerPort: 443, Protocol: "TCP", Name: "https"},
+		}},
+		{Name: "stale", Image: "stale:v1"},
+	}}
+
+	changes, err := deepcopy.OnChangeReportWith(&dst, &src,
+		[]string{"Containers.Image", "Containers.Ports"},
+		deepcopy.WithMergeKeys("Containers", "Name"),
+		deepcopy.WithMergeKeys("Containers.Ports", "ContainerPort", "Protocol"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{
+		"Containers[0].Image",
+		"Containers[0].Ports[0].Name",
+		"Containers[0].Ports[1]",
+		"Containers[0].Ports[1]",
+		"Containers[1]",
+		"Containers[1]",
+	})
+	assert.DeepEqual(t, dst, keyedPod{Containers: []keyedContainer{
+		{Name: "app", Image: "app:v2", Ports: []keyedPort{
+			{ContainerPort: 80, Protocol: "TCP", Name: "http"},
+			{ContainerPort: 80, Protocol: "UDP", Name: "dns"},
+		}},
+		{Name: "sidecar", Image: "sidecar:v1"},
+	}})
+
+	changes, err = deepcopy.OnChangeReportWith(&dst, &src,
+		[]string{"Containers.Image", "Containers.Ports"},
+		deepcopy.WithMergeKeys("Containers", "Name"),
+		deepcopy.WithMergeKeys("Containers.Ports", "ContainerPort", "Protocol"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+
+	changes, err = deepcopy.OnChangeReportWith(&dst, &src, []string{"Containers.Image"},
+		deepcopy.WithMergeKeys("Containers", "Image"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+
+	reordered := keyedPod{Containers: []keyedContainer{dst.Containers[1], dst.Containers[0]}}
+	changes, err = deepcopy.OnChangeReportWith(&dst, &reordered, []string{"Containers"},
+		deepcopy.WithMergeKeys("Containers", "Image"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+}
+
+func TestOnChangeWithMergeKeysInFieldsSelected(t *testing.T) {
+	src := keyedDeployment{Replicas: 2, Spec: &keyedPod{Containers: []keyedContainer{
+		{Name: "sidecar", Image: "sidecar:v1"},
+		{Name: "app", Image: "app:v2", Ports: []keyedPort{{ContainerPort: 443, Name: "https"}, {ContainerPort: 80}}},
+	}}}
+	newDst := func() keyedDeployment {
+		return keyedDeployment{Replicas: 1, Spec: &keyedPod{Containers: []keyedContainer{
+			{Name: "app", Image: "app:v1", Ports: []keyedPort{{ContainerPort: 80, Name: "http"}}},
+			{Name: "stale", Image: "stale:v1"},
+		}}}
+	}
+
+	dst := newDst()
+	changes, err := deepcopy.OnChangeReportWith(&dst, &src, []string{"Spec"},
+		deepcopy.WithMergeKeys("Spec.Containers", "Name"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{
+		"Spec.Containers[0].Image",
+		"Spec.Containers[0].Ports[0].ContainerPort",
+		"Spec.Containers[0].Ports[0].Name",
+		"Spec.Containers[0].Ports[1]",
+		"Spec.Containers[1]",
+		"Spec.Containers[1]",
+	})
+	assert.DeepEqual(t, dst, keyedDeployment{Replicas: 1, Spec: &keyedPod{Containers: []keyedContainer{
+		{Name: "app", Image: "app:v2", Ports: []keyedPort{{ContainerPort: 443, Name: "https"}, {ContainerPort: 80}}},
+		{Name: "sidecar", Image: "sidecar:v1"},
+	}}})
+
+	dst = newDst()
+	changes, err = deepcopy.OnChangeReportWith(&dst, &src, []string{"Spec.Containers"},
+		deepcopy.WithMergeKeys("Spec.Containers", "Name"),
+		deepcopy.WithMergeKeys("Spec.Containers.Ports", "ContainerPort"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{
+		"Spec.Containers[0].Image",
+		"Spec.Containers[0].Ports[0].Name",
+		"Spec.Containers[0].Ports[1]",
+		"Spec.Containers[1]",
+		"Spec.Containers[1]",
+	})
+	assert.DeepEqual(t, dst.Spec.Containers[0].Ports, []keyedPort{{ContainerPort: 80}, {ContainerPort: 443, Name: "https"}})
+
+	dst = newDst()
+	replicator, err := deepcopy.NewPartialReplicatorWith([]string{"Spec"}, deepcopy.WithMerge(),
+		deepcopy.WithMergeKeys("Spec.Containers", "Name"))
+	assert.NilError(t, err)
+	assert.Assert(t, replicator.Copy(&dst, &src))
+	assert.DeepEqual(t, dst, keyedDeployment{Replicas: 1, Spec: &keyedPod{Containers: []keyedContainer{
+		{Name: "app", Image: "app:v2", Ports: []keyedPort{{ContainerPort: 443, Name: "https"}, {ContainerPort: 80}}},
+		{Name: "stale", Image: "stale:v1"},
+		{Name: "sidecar", Image: "sidecar:v1"},
+	}}})
+
+	for path, keys := range map[string][]string{
+		"Spec.Pods":       {"Name"},
+		"Spec.Containers": {"Tag"},
+		"Replicas":        {"Name"},
+	} {
+		_, err = deepcopy.OnChangeReportWith(&dst, &src, []string{"Spec"}, deepcopy.WithMergeKeys(path, keys...))
+		assert.ErrorType(t, err, &deepcopy.PathError{})
+	}
+}
+
+type pointerKeyedElement struct {
+	Name  *string
+	Value int
+}
+
+func TestOnChangeWithPointerMergeKeys(t *testing.T) {
+	name := func(s string) *string { return &s }
+	src := []pointerKeyedElement{{Name: name("b"), Value: 2}, {Name: name("a"), Value: 1}, {Value: 3}}
+	dst := []pointerKeyedElement{{Name: name("a")}, {Name: name("b"), Value: 2}, {Value: 3}}
+
+	changes, err := deepcopy.OnChangeReportWith(&dst, &src, []string{"[*].Value"}, deepcopy.WithMergeKeys("[*]", "Name"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"[0].Value"})
+	assert.Equal(t, dst[0].Value, 1)
+	assert.Equal(t, len(dst), 3)
+}
+
+func TestMergingReplicatorWithMergeKeys(t *testing.T) {
+	src := keyedPod{Containers: []keyedContainer{
+		{Name: "sidecar", Image: "sidecar:v1"},
+		{Name: "app", Image: "app:v2"},
+	}}
+	dst := keyedPod{Containers: []keyedContainer{
+		{Name: "app", Image: "app:v1", Ports: []keyedPort{{ContainerPort: 80}}},
+		{Name: "stale", Image: "stale:v1"},
+	}}
+
+	replicator, err := deepcopy.NewPartialReplicatorWith([]string{"Containers.Name", "Containers.Image"},
+		deepcopy.WithMerge(), deepcopy.WithMergeKeys("Containers[*]", "Name"))
+	assert.NilError(t, err)
+	assert.Assert(t, replicator.Copy(&dst, &src))
+	assert.DeepEqual(t, dst, keyedPod{Containers: []keyedContainer{
+		{Name: "app", Image: "app:v2", Ports: []keyedPort{{ContainerPort: 80}}},
+		{Name: "stale", Image: "stale:v1"},
+		{Name: "sidecar", Image: "sidecar:v1"},
+	}})
+}
@@ -3,6 +3,8 @@ package deepcopy
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -10,22 +12,91 @@ func Partial(dst, src interface{}, fieldsSelected ...string) (copied bool) {
 	return NewPartialReplicator(fieldsSelected...).Copy(dst, src)
 }
 
+// PartialE is like Partial but returns an error instead of panicking.
+func PartialE(dst, src interface{}, fieldsSelected ...string) (copied bool, err error) {
+	return PartialWith(dst, src, fieldsSelected)
+}
+
+// PartialWith is like PartialE but configured by opts.
+func PartialWith(dst, src interface{}, fieldsSelected []string, opts ...Option) (copied bool, err error) {
+	r, err := NewPartialReplicatorWith(fieldsSelected, opts...)
+	if err != nil {
+		return
+	}
+
+	return r.CopyE(dst, src)
+}
+
+// Except deeply copies src into dst except the fields excluded, which are left as they are in dst.
+func Except(dst, src interface{}, fieldsExcluded ...string) (copied bool) {
+	return NewExceptReplicator(fieldsExcluded...).Copy(dst, src)
+}
+
+// ExceptE is like Except but returns an error instead of panicking.
+func ExceptE(dst, src interface{}, fieldsExcluded ...string) (copied bool, err error) {
+	r, err := NewExceptReplicatorE(fieldsExcluded...)
+	if err != nil {
+		return
+	}
+
+	return r.CopyE(dst, src)
+}
+
 func OnChange(dst, src interface{}, fieldsSelected ...string) (copied bool) {
 	return OnChangeD(&traceNothing{}, dst, src, fieldsSelected...)
 }
 
+// OnChangeE is like OnChange but returns an error instead of panicking.
+func OnChangeE(dst, src interface{}, fieldsSelected ...string) (copied bool, err error) {
+	return OnChangeWith(dst, src, fieldsSelected)
+}
+
 func OnChangeD(tracer Tracer, dst, src interface{}, fieldsSelected ...string) (copied bool) {
-	hierarchy := fieldsToTree(fieldsSelected)
-	_, copied = copyPieceChanges(reflect.ValueOf(dst), reflect.ValueOf(src), &hierarchy, &stackTracer{
-		HierarchyStack: HierarchyStack(""),
-		Tracer:         tracer,
-	})
+	copied, err := OnChangeWith(dst, src, fieldsSelected, WithTracer(tracer))
+	if err != nil {
+		panic(err)
+	}
+
 	return copied
 }
 
+// OnChangeWith is like OnChangeE but configured by opts.
+func OnChangeWith(dst, src interface{}, fieldsSelected []string, opts ...Option) (copied bool, err error) {
+	changes, err := OnChangeReportWith(dst, src, fieldsSelected, opts...)
+	return len(changes) > 0, err
+}
+
+func checkObjects(dst, src interface{}) error {
+	if dst == nil {
+		return ErrNilDestination
+	}
+
+	if v := reflect.ValueOf(dst); v.Kind() != reflect.Ptr || v.IsNil() {
+		return ErrNilDestination
+	}
+
+	if reflect.TypeOf(src) != reflect.TypeOf(dst) {
+		return &TypeMismatchError{Src: reflect.TypeOf(src), Dst: reflect.TypeOf(dst)}
+	}
+
+	return nil
+}
+
 type tree struct {
 	branches map[string]tree
 	layer    int
+	// kind, name and index describe the segment the tree grows from.
+	kind  segmentKind
+	name  string
+	index int
+	// selected is true if a field path ends at the tree, so the whole value is selected except
+	// the parts excluded by its branches. excluded is true if an exclusion path ends at the tree.
+	selected bool
+	excluded bool
+	// mergeKeys are names of fields by which elements of the slice the tree applied to are matched.
+	mergeKeys []string
+	// equal compares values the tree applied to if it isn't nil.
+	equal EqualFunc
 }
 
 func (t tree) FindBranch(branchValue string) (branch *tree) {
@@ -36,9 +107,12 @@ func (t tree) FindBranch(branchValue string) (branch *tree) {
 	return
 }
 
-func (t *tree) AddBranch(branchValue string) (branch *tree) {
+func (t *tree) AddBranch(seg segment) (branch *tree) {
 	b := newTree(t.layer + 1)
-	t.branches[branchValue] = b
+	b.kind = seg.kind
+	b.name = seg.name
+	b.index = seg.index
+	t.branches[seg.key()] = b
 	return &b
 }
 
@@ -51,6 +125,185 @@ func (t tree) Trace(tr *stackTracer) {
 	tr.Println(brs)
 }
 
+// mark sets flags of the branch branchValue, since branches are stored by value.
+func (t tree) mark(branchValue string, selected, excluded bool) {
+	b := t.branches[branchValue]
+	b.selected = b.selected || selected
+	b.excluded = b.excluded || excluded
+	t.branches[branchValue] = b
+}
+
+// hasElementBranches tells whether any of the branches selects elements of a slice.
+func (t tree) hasElementBranches() bool {
+	for _, b := range t.branches {
+		if b.kind != segmentField {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasExclusions tells whether any part of the tree is excluded.
+func (t tree) hasExclusions() bool {
+	for _, b := range t.branches {
+		if b.excluded || b.hasExclusions() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectsAllElements tells whether the tree applies to every element of a slice, i.e. it
+// selects fields of all elements or has a wildcard branch.
+func (t tree) selectsAllElements() bool {
+	if !t.hasElementBranches() {
+		return true
+	}
+
+	_, found := t.branches[segment{kind: segmentWildcard}.key()]
+	return found
+}
+
+// elementTree returns the tree applied to the jth element of a slice of length n, which
+// merges field branches of t, which are applied to every element, with branches selecting
+// the element by index or wildcard. The element itself is selected or excluded as a whole
+// if sub is, and every element is selected if t is. found is false if the element is neither
+// selected nor excluded at all.
+func (t tree) elementTree(j, n int) (sub tree, found bool) {
+	if !t.hasElementBranches() {
+		sub = t
+		sub.excluded, sub.mergeKeys = false, nil
+		return sub, len(t.branches) > 0 || t.selected
+	}
+
+	var trees []tree
+	fields := newTree(t.layer)
+	for key, b := range t.branches {
+		switch {
+		case b.kind == segmentField:
+			fields.branches[key] = b
+		case b.kind == segmentWildcard, b.index == j, b.index < 0 && b.index+n == j:
+			trees = append(trees, b)
+		}
+	}
+
+	if len(fields.branches) > 0 || t.selected {
+		fields.selected = t.selected
+		trees = append(trees, fields)
+	}
+
+	switch len(trees) {
+	case 0:
+		return
+	case 1:
+		return trees[0], true
+	}
+
+	sub = newTree(t.layer + 1)
+	for _, b := range trees {
+		sub.graft(b)
+		sub.selected = sub.selected || b.selected
+		sub.excluded = sub.excluded || b.excluded
+		if sub.mergeKeys == nil {
+			sub.mergeKeys = b.mergeKeys
+		}
+
+		if sub.equal == nil {
+			sub.equal = b.equal
+		}
+	}
+
+	return sub, true
+}
+
+// graft copies all branches of other into t.
+func (t *tree) graft(other tree) {
+	for key, b := range other.branches {
+		branch := t.FindBranch(key)
+		if branch == nil {
+			branch = t.AddBranch(segment{kind: b.kind, name: b.name, index: b.index})
+		}
+
+		branch.graft(b)
+		t.mark(key, b.selected, b.excluded)
+		merged := t.branches[key]
+		if merged.mergeKeys == nil {
+			merged.mergeKeys = b.mergeKeys
+		}
+
+		if merged.equal == nil {
+			merged.equal = b.equal
+		}
+
+		t.branches[key] = merged
+	}
+}
+
+// branchMapKey is like mapKey but for the branch keyed by key.
+func branchMapKey(key string, typ reflect.Type) (reflect.Value, bool) {
+	segments, err := parsePath(key)
+	if err != nil || len(segments) != 1 {
+		return reflect.Value{}, false
+	}
+
+	return tree{kind: segments[0].kind, name: segments[0].name, index: segments[0].index}.mapKey(typ)
+}
+
+// mapKey converts the segment the tree grows from into a key of the map type typ. ok is
+// false if the segment can't be a key of the map.
+func (t tree) mapKey(typ reflect.Type) (key reflect.Value, ok bool) {
+	var str string
+	switch t.kind {
+	case segmentField, segmentKey:
+		str = t.name
+	case segmentIndex:
+		str = strconv.Itoa(t.index)
+	default:
+		return
+	}
+
+	keyTyp := typ.Key()
+	key = reflect.New(keyTyp).Elem()
+	switch keyTyp.Kind() {
+	case reflect.String:
+		key.SetString(str)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(str, 10, keyTyp.Bits())
+		if err != nil {
+			return
+		}
+
+		key.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(str, 10, keyTyp.Bits())
+		if err != nil {
+			return
+		}
+
+		key.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, keyTyp.Bits())
+		if err != nil {
+			return
+		}
+
+		key.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return
+		}
+
+		key.SetBool(b)
+	default:
+		return
+	}
+
+	return key, true
+}
+
 func newTree(layerId int) tree {
 	return tree{
 		branches: make(map[string]tree),
@@ -58,40 +311,130 @@ func newTree(layerId int) tree {
 	}
 }
 
-func fieldsToTree(fields []string) (t tree) {
+// fieldsToTree parses fields into a tree. Fields prefixed with "!" are excluded. If all fields
+// are excluded, the whole object is selected except them.
+func fieldsToTree(fields []string) (t tree, err error) {
 	t = newTree(0)
-	for i, field := range fields {
-		if len(field) == 0 {
-			panic(fmt.Sprintf("the %dth field in %#v is empty", i, fields))
+	included := false
+	for _, field := range fields {
+		excluded := strings.HasPrefix(field, "!")
+		var segments []segment
+		if segments, err = parsePath(strings.TrimPrefix(field, "!")); err != nil {
+			err.(*PathError).Path = field
+			return
 		}
 
-		hierarchies := strings.Split(field, ".")
-		cur := &t
-		for h, hierarchy := range hierarchies {
-			if len(hierarchy) == 0 {
-				panic(fmt.Sprintf("field %s contains a blank path at index %d", field, h))
-			}
-
-			if branch := cur.FindBranch(hierarchy); branch != nil {
+		parent, cur := &t, &t
+		for _, seg := range segments {
+			parent = cur
+			if branch := cur.FindBranch(seg.key()); branch != nil {
 				cur = branch
 			} else {
-				cur = cur.AddBranch(hierarchy)
+				cur = cur.AddBranch(seg)
+			}
+		}
+
+		parent.mark(segments[len(segments)-1].key(), !excluded, excluded)
+		included = included || !excluded
+	}
+
+	t.selected = !included && len(fields) > 0
+	return
+}
+
+// validateTree resolves every branch of hierarchy against typ and reports all the segments
+// which are not fields of the structure or keys of the map they are applied to, or can't be
+// traversed.
+func validateTree(typ reflect.Type, hierarchy *tree, keys []string, o *options) (errs PathErrors) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	values := make([]string, 0, len(hierarchy.branches))
+	for value := range hierarchy.branches {
+		values = append(values, value)
+	}
+
+	sort.Strings(values)
+	for _, value := range values {
+		path := append(keys[:len(keys):len(keys)], value)
+		branch := hierarchy.branches[value]
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, &PathError{Path: joinKeys(path), Index: len(keys), Reason: fmt.Sprintf(format, args...)})
+		}
+
+		target := typ
+		if branch.kind == segmentField {
+			target = elemType(typ)
+		}
+
+		switch {
+		case target.Kind() == reflect.Map && branch.kind != segmentWildcard:
+			if _, ok := branch.mapKey(target); !ok {
+				fail("is not a valid key of %s", target)
+				continue
+			}
+
+			errs = append(errs, validateTree(target.Elem(), &branch, path, o)...)
+		case branch.kind == segmentIndex, branch.kind == segmentWildcard:
+			if target.Kind() != reflect.Slice {
+				fail("can't be applied to %s which is not a slice", target)
+				continue
 			}
+
+			errs = append(errs, validateTree(target.Elem(), &branch, path, o)...)
+		case branch.kind == segmentKey:
+			fail("can't be applied to %s which is not a map", target)
+		case target.Kind() != reflect.Struct:
+			fail("can't be traversed since %s is a %s", target, target.Kind())
+		default:
+			field := planOf(target).field(target, value, o.resolver)
+			if field == nil {
+				fail("is not an exported field of %s", target)
+				continue
+			}
+
+			errs = append(errs, validateTree(target.FieldByIndex(field.index).Type, &branch, path, o)...)
 		}
 	}
 
 	return
 }
 
-func inspectObject(in reflect.Value, hierarchy *tree) (mimic reflect.Value, copied bool) {
-	if in.Kind() != reflect.Ptr && in.Kind() != reflect.Slice && in.Kind() != reflect.Struct {
-		panic(fmt.Sprintf("the object should be a pointer, structure or slice but %s", in.Kind()))
+// elemType strips pointers and slices off typ.
+func elemType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+
+	return typ
+}
+
+// isZero tells whether v is the zero value of its type. Maps are zero only if they are nil.
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Ptr, reflect.Interface, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	default:
+		return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+	}
+}
+
+func inspectObject(in reflect.Value, hierarchy *tree, o *options) (mimic reflect.Value, copied bool, err error) {
+	if in.Kind() != reflect.Ptr && in.Kind() != reflect.Slice && in.Kind() != reflect.Struct &&
+		in.Kind() != reflect.Map {
+		err = &KindError{Kind: in.Kind()}
+		return
 	}
 
 	mimic = reflect.New(in.Type()).Elem()
 	out := mimic
 
 	if in.Kind() == reflect.Ptr {
+		if in.IsNil() {
+			return
+		}
+
 		in = in.Elem()
 		if !out.Elem().IsValid() {
 			out.Set(reflect.New(in.Type()))
@@ -101,46 +444,298 @@ func inspectObject(in reflect.Value, hierarchy *tree) (mimic reflect.Value, copi
 	}
 
 	if in.Kind() == reflect.Slice {
-		slice := reflect.Zero(in.Type())
+		if in.Len() == 0 {
+			return
+		}
+
+		slice := reflect.MakeSlice(in.Type(), in.Len(), in.Len())
 		for j := 0; j < in.Len(); j++ {
-			var elem reflect.Value
-			elem, copied = inspectObject(in.Index(j), hierarchy)
-			if elem.IsValid() {
-				slice = reflect.Append(slice, elem)
+			sub, found := hierarchy.elementTree(j, in.Len())
+			if !found || sub.excluded {
+				continue
 			}
+
+			if sub.selected {
+				if !isZero(in.Index(j)) {
+					copied = true
+					if err = copyPruned(in.Index(j), slice.Index(j), &sub, o); err != nil {
+						return
+					}
+				}
+				continue
+			}
+
+			elem, elemCopied, elemErr := inspectObject(in.Index(j), &sub, o)
+			if elemErr != nil {
+				err = elemErr
+				return
+			}
+
+			slice.Index(j).Set(elem)
+			copied = copied || elemCopied
 		}
 
 		out.Set(slice)
 		return
 	}
 
+	if in.Kind() == reflect.Map {
+		copied, err = inspectMap(in, out, hierarchy, o)
+		return
+	}
+
+	if in.Kind() != reflect.Struct {
+		err = &KindError{Kind: in.Kind()}
+		return
+	}
+
 	for value, branch := range hierarchy.branches {
-		nextIn := in.FieldByName(value)
-		nextOut := out.FieldByName(value)
+		nextIn, mode := fieldByName(in, value, o.resolver)
+		if !nextIn.IsValid() || branch.excluded || mode == copySkip || mode == copyZero {
+			continue
+		}
 
-		if len(branch.branches) == 0 {
-			if !nextIn.IsValid() {
-				continue
+		if branch.selected || mode == copyShallow {
+			if !isZero(nextIn) {
+				copied = true
+				nextOut := settableField(out, value, o.resolver)
+				if mode == copyDeep {
+					err = copyPruned(nextIn, nextOut, &branch, o)
+				} else {
+					err = copyField(nextIn, nextOut, mode, o)
+				}
+
+				if err != nil {
+					return
+				}
+			}
+		} else {
+			v, elemCopied, elemErr := inspectObject(nextIn, &branch, o)
+			if elemErr != nil {
+				err = elemErr
+				return
 			}
 
-			if nextIn.Kind() == reflect.Map && !nextIn.IsNil() ||
-				reflect.Zero(nextIn.Type()).Interface() != nextIn.Interface() {
-				copied = true
-				copyRecursive(nextIn, nextOut)
+			// Fields of the mimic are zero, so only those not zero are set, which also keeps
+			// embedded pointers nil if nothing in them is set.
+			if !isZero(v) {
+				settableField(out, value, o.resolver).Set(v)
 			}
+
+			copied = copied || elemCopied
+		}
+	}
+
+	return
+}
+
+// inspectMap copies entries of in selected by branches of hierarchy into out.
+func inspectMap(in, out reflect.Value, hierarchy *tree, o *options) (copied bool, err error) {
+	if in.IsNil() {
+		return
+	}
+
+	m := reflect.MakeMap(in.Type())
+	for _, branch := range hierarchy.branches {
+		key, ok := branch.mapKey(in.Type())
+		if !ok {
+			continue
+		}
+
+		nextIn := in.MapIndex(key)
+		if !nextIn.IsValid() || branch.excluded {
+			continue
+		}
+
+		nextOut := reflect.New(in.Type().Elem()).Elem()
+		if branch.selected {
+			if err = copyPruned(nextIn, nextOut, &branch, o); err != nil {
+				return
+			}
+
+			copied = true
 		} else {
-			var v reflect.Value
-			v, copied = inspectObject(nextIn, &branch)
+			v, elemCopied, elemErr := inspectObject(nextIn, &branch, o)
+			if elemErr != nil {
+				err = elemErr
+				return
+			}
+
+			if !elemCopied {
+				continue
+			}
+
 			nextOut.Set(v)
+			copied = true
+		}
+
+		m.SetMapIndex(key, nextOut)
+	}
+
+	if copied {
+		out.Set(m)
+	}
+
+	return
+}
+
+// mergeObject copies fields of src selected by hierarchy into dst in place, leaving the others
+// in dst as they are. Pointers, slices and maps on the way are allocated only if they are nil in
+// dst, and slices shorter than those in src are grown.
+func mergeObject(dst, src reflect.Value, hierarchy *tree, o *options) (copied bool, err error) {
+	if src.Kind() == reflect.Ptr {
+		if src.IsNil() {
+			return
+		}
+
+		if dst.IsNil() {
+			dst.Set(reflect.New(src.Type().Elem()))
+		}
+
+		src, dst = src.Elem(), dst.Elem()
+	}
+
+	switch src.Kind() {
+	case reflect.Slice:
+		if src.Len() == 0 {
+			return
+		}
+
+		if len(hierarchy.mergeKeys) > 0 {
+			return mergeKeyedSlice(dst, src, hierarchy, o)
+		}
+
+		if dst.Len() < src.Len() {
+			slice := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+			reflect.Copy(slice, dst)
+			dst.Set(slice)
+		}
+
+		for j := 0; j < src.Len(); j++ {
+			sub, found := hierarchy.elementTree(j, src.Len())
+			if !found || sub.excluded {
+				continue
+			}
+
+			elemCopied, elemErr := mergeValue(dst.Index(j), src.Index(j), &sub, copyDeep, o)
+			if elemErr != nil {
+				err = elemErr
+				return
+			}
+
+			copied = copied || elemCopied
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+
+		for _, branch := range hierarchy.branches {
+			key, ok := branch.mapKey(src.Type())
+			if !ok {
+				continue
+			}
+
+			nextIn := src.MapIndex(key)
+			if !nextIn.IsValid() || branch.excluded {
+				continue
+			}
+
+			// Entries of maps are not addressable, so the entry is merged into a copy of it which
+			// is then put back.
+			elem := reflect.New(src.Type().Elem()).Elem()
+			if !dst.IsNil() && dst.MapIndex(key).IsValid() {
+				elem.Set(dst.MapIndex(key))
+			}
+
+			elemCopied, elemErr := mergeValue(elem, nextIn, &branch, copyDeep, o)
+			if elemErr != nil {
+				err = elemErr
+				return
+			}
+
+			if !elemCopied && !branch.selected {
+				continue
+			}
+
+			if dst.IsNil() {
+				dst.Set(reflect.MakeMap(src.Type()))
+			}
+
+			dst.SetMapIndex(key, elem)
+			copied = true
+		}
+	case reflect.Struct:
+		if hierarchy.selected {
+			// The structure selected as a whole is merged field by field.
+			expanded := hierarchy.expand(src.Type(), o)
+			hierarchy = &expanded
+		}
+
+		for value, branch := range hierarchy.branches {
+			nextIn, mode := fieldByName(src, value, o.resolver)
+			if !nextIn.IsValid() || branch.excluded || mode == copySkip {
+				continue
+			}
+
+			if mode == copyZero {
+				// Fields are reset in copies, so they are reset in the destination merged as well.
+				if nextOut, _ := fieldByName(dst, value, o.resolver); !isZero(nextOut) {
+					nextOut.Set(reflect.Zero(nextOut.Type()))
+					copied = true
+				}
+
+				continue
+			}
+
+			nextOut, _, detached := lookupField(dst, value, o.resolver, false)
+			if detached {
+				if isZero(nextIn) {
+					// The field promoted from a nil embedded pointer is zero already.
+					continue
+				}
+
+				nextOut = settableField(dst, value, o.resolver)
+			}
+
+			elemCopied, elemErr := mergeValue(nextOut, nextIn, &branch, mode, o)
+			if elemErr != nil {
+				err = elemErr
+				return
+			}
+
+			copied = copied || elemCopied
 		}
+	default:
+		err = &KindError{Kind: src.Kind()}
 	}
 
 	return
 }
 
-func copyPieceChanges(dst, src reflect.Value, hierarchy *tree, tr *stackTracer) (mimic reflect.Value, copied bool) {
-	if src.Kind() != reflect.Ptr && src.Kind() != reflect.Slice && src.Kind() != reflect.Struct {
-		panic(fmt.Sprintf("the object should be a pointer, structure or slice but %s", src.Kind()))
+// mergeValue copies src into dst if it is selected as a whole, or merges its selected parts.
+func mergeValue(dst, src reflect.Value, hierarchy *tree, mode copyMode, o *options) (copied bool, err error) {
+	if mode == copyDeep && (!hierarchy.selected || hierarchy.matchesByKeys(src.Type()) ||
+		reflect.Indirect(src).Kind() == reflect.Struct && hierarchy.hasKeyedBranches() &&
+			hierarchy.walksThrough(dst, src, nil, o)) {
+		return mergeObject(dst, src, hierarchy, o)
+	}
+
+	if mode == copyDeep {
+		err = copyPruned(src, dst, hierarchy, o)
+	} else {
+		err = copyField(src, dst, mode, o)
+	}
+
+	return !isZero(src), err
+}
+
+func copyPieceChanges(dst, src reflect.Value, hierarchy *tree, tr *stackTracer, rec *changeRecorder, o *options) (
+	mimic reflect.Value, copied bool, err error) {
+	if src.Kind() != reflect.Ptr && src.Kind() != reflect.Slice && src.Kind() != reflect.Struct &&
+		src.Kind() != reflect.Map {
+		err = &KindError{Kind: src.Kind()}
+		return
 	}
 
 	hierarchy.Trace(tr)
@@ -157,8 +752,28 @@ func copyPieceChanges(dst, src reflect.Value, hierarchy *tree, tr *stackTracer)
 	out := mimic
 
 	if src.Kind() == reflect.Ptr {
+		if src.IsNil() {
+			tr.PrintfLn("Source field【%s】is a nil pointer. Skip!", tr.Prefix())
+			return
+		}
+
 		src = src.Elem()
-		if !out.Elem().IsValid() {
+		switch {
+		case o.dryRun:
+			// What out points to is shared, so changes are made to a copy of it.
+			shadow := reflect.New(src.Type())
+			if !out.IsNil() {
+				shadow.Elem().Set(out.Elem())
+			}
+
+			if out.CanSet() {
+				out.Set(shadow)
+			} else {
+				mimic = shadow
+			}
+
+			out = shadow
+		case !out.Elem().IsValid():
 			out.Set(reflect.New(src.Type()))
 		}
 
@@ -166,57 +781,469 @@ func copyPieceChanges(dst, src reflect.Value, hierarchy *tree, tr *stackTracer)
 	}
 
 	if src.Kind() == reflect.Slice {
-		slice := reflect.Zero(src.Type())
-		for j := 0; j < src.Len(); j++ {
-			tr.PrintfLn("Source field【%s】is a %s! Go through the %dth element!", tr.Prefix(),
-				reflect.Slice.String(), j)
-			elem, elemCopied := copyPieceChanges(out.Index(j), src.Index(j), hierarchy, tr)
-			if elem.IsValid() {
-				slice = reflect.Append(slice, elem)
-			}
-			copied = copied || elemCopied
-		}
+		copied, err = copySliceChanges(out, src, hierarchy, tr, rec, o)
+		return
+	}
+
+	if src.Kind() == reflect.Map {
+		copied, err = copyMapChanges(out, src, hierarchy, tr, rec, o)
+		return
+	}
+
+	if src.Kind() != reflect.Struct {
+		err = &KindError{Kind: src.Kind()}
 		return
 	}
 
+	if o.dryRun {
+		shadowEmbedded(out)
+	}
+
+	if hierarchy.selected {
+		// The structure selected as a whole is walked through field by field.
+		expanded := hierarchy.expand(src.Type(), o)
+		hierarchy = &expanded
+	}
+
 	for value, branch := range hierarchy.branches {
 		tr.PrintfLn("=======================Detect branch【%s.%s】======================", tr.Prefix(), value)
-		nextIn := src.FieldByName(value)
-		nextOut := out.FieldByName(value)
+		nextIn, mode := fieldByName(src, value, o.resolver)
+		// Fields promoted from nil embedded pointers are changed apart and set if changed, so that
+		// the pointers are only allocated on change.
+		nextOut, _, detached := lookupField(out, value, o.resolver, false)
 		var elemCopied bool
 
-		if len(branch.branches) == 0 {
-			if !nextIn.IsValid() {
-				tr.PrintfLn("Can't found field %s.%s in Source. Skip!", tr.Prefix(), value)
-				tr.PrintfLn("========================End branch【%s.%s】========================", tr.Prefix(), value)
-				continue
-			}
+		if !nextIn.IsValid() {
+			tr.PrintfLn("Can't found field %s.%s in Source. Skip!", tr.Prefix(), value)
+			tr.PrintfLn("========================End branch【%s.%s】========================", tr.Prefix(), value)
+			continue
+		}
 
-			switch nextIn.Kind() {
-			case reflect.Map,
-				reflect.Struct,
-				reflect.Slice:
-				elemCopied = !reflect.DeepEqual(nextIn.Interface(), nextOut.Interface())
-			default:
-				elemCopied = nextIn.Interface() != nextOut.Interface()
-			}
+		if mode == copySkip || branch.excluded {
+			tr.PrintfLn("Field %s.%s is tagged to be skipped or excluded. Skip!", tr.Prefix(), value)
+			tr.PrintfLn("========================End branch【%s.%s】========================", tr.Prefix(), value)
+			continue
+		}
 
+		rec.push(value)
+		if mode != copyDeep || branch.selected && !branch.walksThrough(nextOut, nextIn, rec.keys, o) {
 			tr.PrintfLn("Source: %#v", nextIn.Interface())
 			tr.PrintfLn("Destination: %#v", nextOut.Interface())
-			tr.PrintfLn("Source field【%s.%s】is a %s! Copied? %t", tr.Prefix(), value, nextIn.Kind().String(), copied)
-			if elemCopied {
-				copyRecursive(nextIn, nextOut)
+			old := snapshot(nextOut)
+			elemCopied, err = copyLeafChanges(nextOut, nextIn, &branch, mode, false, rec, o)
+			if err == ErrVetoChange {
+				err = nil
+			} else if err != nil {
+				return
+			}
+
+			if elemCopied && mode == copyDeep {
+				rec.recordSelected(old, nextOut, &branch, o)
+			} else if elemCopied {
+				rec.record(old.Interface(), nextOut.Interface())
 			}
+
+			tr.PrintfLn("Source field【%s.%s】is a %s! Copied? %t", tr.Prefix(), value, nextIn.Kind().String(),
+				elemCopied)
 		} else {
 			tr.PrintfLn("Source field【%s.%s】has branches. Go through!", tr.Prefix(), value)
 			tr.Push(value)
-			_, elemCopied = copyPieceChanges(nextOut, nextIn, &branch, tr)
+			_, elemCopied, err = copyPieceChanges(nextOut, nextIn, &branch, tr, rec, o)
+			if err != nil {
+				return
+			}
+
 			tr.PrintfLn("Source field【%s.%s】Copied? %t", tr.Prefix(), value, copied)
 		}
 
+		if elemCopied && detached {
+			settableField(out, value, o.resolver).Set(nextOut)
+		}
+
+		rec.pop()
+
 		copied = copied || elemCopied
 		tr.PrintfLn("========================End branch【%s.%s】========================", tr.Prefix(), value)
 	}
 
 	return
 }
+
+// copySliceChanges copies elements of src selected by hierarchy into the slice dst if they are
+// different. If every element is selected, elements absent from dst are appended and those absent
+// from src are removed, otherwise only elements in both slices are copied, in which negative
+// indexes are counted from the end of dst, and selecting elements absent from either slice is an
+// error. Elements are matched by their indexes, or by merge keys if hierarchy has any.
+func copySliceChanges(dst, src reflect.Value, hierarchy *tree, tr *stackTracer, rec *changeRecorder, o *options) (
+	copied bool, err error) {
+	if len(hierarchy.mergeKeys) > 0 {
+		return copyKeyedSliceChanges(dst, src, hierarchy, tr, rec, o)
+	}
+
+	n, length := src.Len(), src.Len()
+	if !hierarchy.selectsAllElements() {
+		// Only elements in both slices are copied, which are selected by indexes in dst.
+		if err = checkIndexes(hierarchy, rec.keys, dst.Len(), src.Len()); err != nil {
+			return
+		}
+
+		if dst.Len() < n {
+			n = dst.Len()
+		}
+
+		length = dst.Len()
+	}
+
+	if dst.Len() < n || o.dryRun && dst.Len() > 0 {
+		// Elements are appended to a new slice in case the one in dst shares its underlying array,
+		// which is also left untouched in dry runs.
+		cloneSlice(dst, n)
+	}
+
+	for j := 0; j < n; j++ {
+		sub, found := hierarchy.elementTree(j, length)
+		if j >= dst.Len() {
+			var elem reflect.Value
+			if elem, err = copyNewElement(src.Index(j), &sub, found, tr, o); err != nil {
+				return
+			}
+
+			rec.push("[" + strconv.Itoa(j) + "]")
+			if err = o.allowChange(rec.keys, reflect.Value{}, elem); err != nil {
+				rec.pop()
+				if err == ErrVetoChange {
+					err = nil
+					break
+				}
+
+				return
+			}
+
+			tr.PrintfLn("The %dth element of source field【%s】is appended!", j, tr.Prefix())
+			rec.recordResize(nil, elem.Interface(), resizeInsert)
+			rec.pop()
+			dst.Set(reflect.Append(dst, elem))
+			copied = true
+			continue
+		}
+
+		if !found || sub.excluded {
+			continue
+		}
+
+		tr.PrintfLn("Source field【%s】is a %s! Go through the %dth element!", tr.Prefix(),
+			reflect.Slice.String(), j)
+		rec.push("[" + strconv.Itoa(j) + "]")
+		elemCopied, elemErr := copyElementChanges(dst.Index(j), src.Index(j), &sub, tr, rec, o)
+		if elemErr != nil {
+			err = elemErr
+			return
+		}
+
+		rec.pop()
+		copied = copied || elemCopied
+	}
+
+	if !hierarchy.selectsAllElements() || dst.Len() <= src.Len() {
+		return
+	}
+
+	// Elements are removed from the last one, so that those before the one vetoed are kept.
+	n = src.Len()
+	for j := dst.Len() - 1; j >= src.Len(); j-- {
+		rec.push("[" + strconv.Itoa(j) + "]")
+		if err = o.allowChange(rec.keys, dst.Index(j), reflect.Value{}); err != nil {
+			rec.pop()
+			if err == ErrVetoChange {
+				err = nil
+				n = j + 1
+				break
+			}
+
+			return
+		}
+
+		tr.PrintfLn("The %dth element of destination field【%s】is removed!", j, tr.Prefix())
+		rec.recordResize(dst.Index(j).Interface(), nil, resizeRemove)
+		rec.pop()
+	}
+
+	switch {
+	case n == dst.Len():
+		return
+	case n == 0 && src.IsNil():
+		dst.Set(reflect.Zero(dst.Type()))
+	default:
+		// Elements removed are kept out of reach of appending to dst.
+		dst.Set(dst.Slice3(0, n, n))
+	}
+
+	copied = true
+	return
+}
+
+// checkIndexes checks that elements selected by indexes in hierarchy, which are counted from the
+// end of dst if negative, are in both dst and src. keys are those of the path of the slices.
+func checkIndexes(hierarchy *tree, keys []string, dstLen, srcLen int) error {
+	for key, b := range hierarchy.branches {
+		if b.kind != segmentIndex || b.excluded {
+			continue
+		}
+
+		j := b.index
+		if j < 0 {
+			j += dstLen
+		}
+
+		if j < 0 || j >= dstLen || j >= srcLen {
+			return &PathError{Path: joinKeys(append(keys[:len(keys):len(keys)], key)), Index: len(keys),
+				Reason: "selects an element out of range"}
+		}
+	}
+
+	return nil
+}
+
+// copyElementChanges copies the element src of a slice into dst if they are different. The element
+// is reported as a whole if it is selected, otherwise its fields changed are.
+func copyElementChanges(dst, src reflect.Value, sub *tree, tr *stackTracer, rec *changeRecorder, o *options) (
+	copied bool, err error) {
+	if !sub.selected || sub.walksThrough(dst, src, rec.keys, o) {
+		_, copied, err = copyPieceChanges(dst, src, sub, tr, rec, o)
+		return
+	}
+
+	old := snapshot(dst)
+	if copied, err = copyLeafChanges(dst, src, sub, copyDeep, false, rec, o); err == ErrVetoChange {
+		return false, nil
+	} else if err != nil {
+		return
+	}
+
+	if copied {
+		rec.recordSelected(old, dst, sub, o)
+	}
+
+	tr.PrintfLn("The element of source field【%s】is selected! Copied? %t", tr.Prefix(), copied)
+	return
+}
+
+// copyNewElement copies parts of the element src of a slice selected by sub into a new element,
+// which is zero if the element isn't found in the tree.
+func copyNewElement(src reflect.Value, sub *tree, found bool, tr *stackTracer, o *options) (
+	elem reflect.Value, err error) {
+	elem = reflect.New(src.Type()).Elem()
+	if found && !sub.excluded {
+		_, err = copyElementChanges(elem, src, sub, tr, &changeRecorder{}, o)
+	}
+
+	return
+}
+
+// copyMapChanges copies entries of src selected by branches of hierarchy into the map dst if they
+// are different. Selected entries absent from src are removed from dst. dst is created if it is nil.
+func copyMapChanges(dst, src reflect.Value, hierarchy *tree, tr *stackTracer, rec *changeRecorder, o *options) (
+	copied bool, err error) {
+	if hierarchy.selected {
+		// The map selected as a whole is walked through entry by entry.
+		expanded, _ := hierarchy.expandMap(dst, src)
+		hierarchy = &expanded
+	}
+
+	// Entries are set to a copy of dst in dry runs, which is made before the first one is set.
+	cloned := !o.dryRun
+	for value, branch := range hierarchy.branches {
+		key, ok := branch.mapKey(src.Type())
+		if !ok {
+			tr.PrintfLn("%s can't be a key of source field【%s】. Skip!", value, tr.Prefix())
+			continue
+		}
+
+		nextIn := src.MapIndex(key)
+		var nextOut reflect.Value
+		if !dst.IsNil() {
+			nextOut = dst.MapIndex(key)
+		}
+
+		var elemCopied bool
+		if branch.excluded {
+			tr.PrintfLn("Source entry【%s.%s】is excluded. Skip!", tr.Prefix(), value)
+			continue
+		}
+
+		rec.push(value)
+		walk := nextIn.IsValid() && nextOut.IsValid() && branch.walksThrough(nextOut, nextIn, rec.keys, o)
+		if branch.selected && !walk {
+			switch {
+			case !nextIn.IsValid() && !nextOut.IsValid():
+			case !nextIn.IsValid():
+				if err = o.allowChange(rec.keys, nextOut, reflect.Value{}); err == ErrVetoChange {
+					err = nil
+					break
+				} else if err != nil {
+					return
+				}
+
+				elemCopied = true
+				rec.record(nextOut.Interface(), nil)
+				prepareMap(dst, src.Type(), &cloned)
+				dst.SetMapIndex(key, reflect.Value{})
+			default:
+				// Entries of maps are not addressable, so changes are made to a copy of the entry
+				// which is then put back.
+				elem := reflect.New(src.Type().Elem()).Elem()
+				if nextOut.IsValid() {
+					elem.Set(nextOut)
+				}
+
+				elemCopied, err = copyLeafChanges(elem, nextIn, &branch, copyDeep, !nextOut.IsValid(), rec, o)
+				if err == ErrVetoChange {
+					err = nil
+					break
+				} else if err != nil {
+					return
+				}
+
+				if elemCopied && nextOut.IsValid() {
+					rec.recordSelected(nextOut, elem, &branch, o)
+				} else if elemCopied {
+					rec.record(nil, elem.Interface())
+				}
+
+				if elemCopied {
+					prepareMap(dst, src.Type(), &cloned)
+					dst.SetMapIndex(key, elem)
+				}
+			}
+
+			tr.PrintfLn("Source entry【%s.%s】Copied? %t", tr.Prefix(), value, elemCopied)
+		} else if nextIn.IsValid() {
+			// Entries of maps are not addressable, so changes are made to a copy of the entry
+			// which is then put back.
+			elem := reflect.New(src.Type().Elem()).Elem()
+			if nextOut.IsValid() {
+				elem.Set(nextOut)
+			}
+
+			tr.Push(value)
+			_, elemCopied, err = copyPieceChanges(elem, nextIn, &branch, tr, rec, o)
+			if err != nil {
+				return
+			}
+
+			if elemCopied {
+				prepareMap(dst, src.Type(), &cloned)
+				dst.SetMapIndex(key, elem)
+			}
+
+			tr.PrintfLn("Source entry【%s.%s】Copied? %t", tr.Prefix(), value, elemCopied)
+		}
+
+		rec.pop()
+		copied = copied || elemCopied
+	}
+
+	return
+}
+
+// prepareMap makes the map dst of typ ready for entries to be set. If cloned is false, dst is
+// replaced with a copy of it, so that the map dst was is left untouched.
+func prepareMap(dst reflect.Value, typ reflect.Type, cloned *bool) {
+	switch {
+	case dst.IsNil():
+		dst.Set(reflect.MakeMap(typ))
+	case !*cloned:
+		m := reflect.MakeMapWithSize(typ, dst.Len())
+		for iter := dst.MapRange(); iter.Next(); {
+			m.SetMapIndex(iter.Key(), iter.Value())
+		}
+
+		dst.Set(m)
+	}
+
+	*cloned = true
+}
+
+// cloneSlice replaces the slice dst with a copy of it, whose capacity is at least n.
+func cloneSlice(dst reflect.Value, n int) {
+	if n < dst.Len() {
+		n = dst.Len()
+	}
+
+	slice := reflect.MakeSlice(dst.Type(), dst.Len(), n)
+	reflect.Copy(slice, dst)
+	dst.Set(slice)
+}
+
+// shadowEmbedded replaces pointers embedded in the structure v, as well as those embedded in
+// them, with pointers to copies of what they point to, so that fields promoted from them can be
+// changed without touching the values shared.
+func shadowEmbedded(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).Anonymous {
+			continue
+		}
+
+		field := v.Field(i)
+		if !field.CanSet() {
+			// Fields promoted from unexported embedded types are still accessible.
+			field = exposeField(field)
+		}
+
+		if field.Kind() == reflect.Ptr && !field.IsNil() {
+			shadow := reflect.New(field.Type().Elem())
+			shadow.Elem().Set(field.Elem())
+			field.Set(shadow)
+			field = shadow.Elem()
+		}
+
+		if field.Kind() == reflect.Struct {
+			shadowEmbedded(field)
+		}
+	}
+}
+
+// copyLeafChanges copies src, which is selected as a whole except the parts excluded by hierarchy,
+// into dst according to mode if they are not equal. rec tells the path of src. absent is true if
+// dst is a zero value standing for a value absent from the destination, e.g. an entry of a map,
+// in which case src is copied even if it is zero, and hooks are called with an invalid old value.
+func copyLeafChanges(dst, src reflect.Value, hierarchy *tree, mode copyMode, absent bool, rec *changeRecorder,
+	o *options) (copied bool, err error) {
+	old := dst
+	if absent {
+		old = reflect.Value{}
+	}
+
+	if mode == copyDeep && hierarchy.hasExclusions() {
+		candidate := reflect.New(dst.Type()).Elem()
+		candidate.Set(dst)
+		if err = copyPruned(src, candidate, hierarchy, o); err != nil {
+			return
+		}
+
+		if copied = absent || !o.equalAt(rec.keys, candidate, dst); copied {
+			if err = o.allowChange(rec.keys, old, candidate); err != nil {
+				return false, err
+			}
+
+			dst.Set(candidate)
+		}
+
+		return
+	}
+
+	if mode == copyZero {
+		src = reflect.Zero(src.Type())
+	}
+
+	copied = absent || !o.equalAt(rec.keys, src, dst)
+	if copied {
+		if err = o.allowChange(rec.keys, old, src); err != nil {
+			return false, err
+		}
+
+		err = copyField(src, dst, mode, o)
+	}
+
+	return
+}
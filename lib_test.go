@@ -270,3 +270,82 @@ func TestStructWithSliceInSlice(t *testing.T) {
 		"Spec.InitContainers.Ports.Name",
 		"Spec.InitContainers.Ports.HostPort"))
 }
+
+func TestSliceIndexesInFieldPaths(t *testing.T) {
+	src := structWithSliceOfPointers{
+		SliceA: []*simpleStruct{
+			{FieldA: "SliceA", FieldB: 102, FieldC: 91.9},
+			{FieldA: "SliceB", FieldB: 101, FieldC: 9.9},
+			{FieldA: "SliceC", FieldB: 100, FieldC: 0.9},
+		},
+	}
+
+	var dst structWithSliceOfPointers
+	assert.Assert(t, deepcopy.Partial(&dst, &src, "SliceA[0].FieldA", "SliceA[-1]", "SliceA[*].FieldB"))
+	assert.Equal(t, len(dst.SliceA), 3)
+	assert.Equal(t, *dst.SliceA[0], simpleStruct{FieldA: "SliceA", FieldB: 102})
+	assert.Equal(t, *dst.SliceA[1], simpleStruct{FieldB: 101})
+	assert.Equal(t, *dst.SliceA[2], *src.SliceA[2])
+	assert.Assert(t, dst.SliceA[2] != src.SliceA[2])
+
+	dst.SliceA[1].FieldA = "Changed"
+	dst.SliceA[2].FieldA = "Changed"
+	assert.Assert(t, !deepcopy.OnChange(&dst, &src, "SliceA[0].FieldA", "SliceA[*].FieldB"))
+	assert.Assert(t, deepcopy.OnChange(&dst, &src, "SliceA[1].FieldC", "SliceA[-1]"))
+	assert.Equal(t, dst.SliceA[1].FieldA, "Changed")
+	assert.Equal(t, dst.SliceA[1].FieldC, src.SliceA[1].FieldC)
+	assert.Equal(t, *dst.SliceA[2], *src.SliceA[2])
+
+	_, err := deepcopy.PartialE(&dst, &src, "SliceA[x]")
+	_, ok := err.(*deepcopy.PathError)
+	assert.Assert(t, ok, "%#v", err)
+
+	_, err = deepcopy.NewTypedPartialReplicator(&src, "SliceA[0].FieldA", "IntA[1]", "SliceA[*].FieldX")
+	errs, ok := err.(deepcopy.PathErrors)
+	assert.Assert(t, ok, "%#v", err)
+	assert.Equal(t, len(errs), 2, err.Error())
+	assert.Equal(t, errs[0].Path, "IntA[1]")
+	assert.Equal(t, errs[1].Path, "SliceA[*].FieldX")
+	assert.Equal(t, errs[1].Index, 2)
+}
+
+type structWithMaps struct {
+	Labels  map[string]string
+	Data    map[string][]byte
+	Items   map[string]simpleStruct
+	Weights map[int]*simpleStruct
+}
+
+func TestMapKeysInFieldPaths(t *testing.T) {
+	src := structWithMaps{
+		Labels: map[string]string{"app": "A", "tier": "B"},
+		Data:   map[string][]byte{"config.yaml": []byte("a: b"), "other": []byte("c")},
+		Items: map[string]simpleStruct{
+			"first": {FieldA: "A", FieldB: 1},
+		},
+		Weights: map[int]*simpleStruct{
+			1: {FieldA: "B", FieldB: 2},
+		},
+	}
+
+	var dst structWithMaps
+	assert.Assert(t, deepcopy.Partial(&dst, &src,
+		"Labels.app", `Data["config.yaml"]`, "Items.first.FieldA", "Weights[1].FieldB", "Labels.missing"))
+	assert.DeepEqual(t, dst.Labels, map[string]string{"app": "A"})
+	assert.DeepEqual(t, dst.Data, map[string][]byte{"config.yaml": []byte("a: b")})
+	assert.DeepEqual(t, dst.Items, map[string]simpleStruct{"first": {FieldA: "A"}})
+	assert.Equal(t, *dst.Weights[1], simpleStruct{FieldB: 2})
+
+	dst = structWithMaps{Labels: map[string]string{"app": "A", "tier": "C", "gone": "D"}}
+	assert.Assert(t, !deepcopy.OnChange(&dst, &src, "Labels.app"))
+	assert.Assert(t, deepcopy.OnChange(&dst, &src, "Labels.tier", "Labels.gone", "Items.first.FieldB"))
+	assert.DeepEqual(t, dst.Labels, map[string]string{"app": "A", "tier": "B"})
+	assert.DeepEqual(t, dst.Items, map[string]simpleStruct{"first": {FieldB: 1}})
+
+	_, err := deepcopy.NewTypedPartialReplicator(&src, "Labels.app", "Weights.x", `Items["a.b"].FieldX`)
+	errs, ok := err.(deepcopy.PathErrors)
+	assert.Assert(t, ok, "%#v", err)
+	assert.Equal(t, len(errs), 2, err.Error())
+	assert.Equal(t, errs[0].Path, `Items["a.b"].FieldX`)
+	assert.Equal(t, errs[1].Path, "Weights.x")
+}
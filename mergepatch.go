@@ -0,0 +1,291 @@
+package deepcopy
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// MergePatch returns the JSON Merge Patch (RFC 7386) which makes the fields selected of old the
+// same as those of new, i.e. what OnChange would change in old. Both must be pointers of the same
+// type. Names in the patch are those in json tags, values removed are null, and slices are
+// replaced as a whole. old is left untouched.
+func MergePatch(old, new interface{}, fieldsSelected ...string) ([]byte, error) {
+	return MergePatchWith(old, new, fieldsSelected)
+}
+
+// MergePatchWith is like MergePatch but configured by opts.
+func MergePatchWith(old, new interface{}, fieldsSelected []string, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	if err := checkObjects(old, new); err != nil {
+		return nil, err
+	}
+
+	o.dryRun = true
+	rec, err := recordChanges(old, new, fieldsSelected, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldDoc, newDoc interface{}
+	if err = decodeJSONOf(old, &oldDoc); err != nil {
+		return nil, err
+	}
+
+	if err = decodeJSONOf(rec.result.Interface(), &newDoc); err != nil {
+		return nil, err
+	}
+
+	d := &differ{old: reflect.ValueOf(old).Elem(), resolver: o.resolver, created: make(map[string]bool)}
+	patch := map[string]interface{}{}
+	for _, step := range rec.steps {
+		t, err := d.target(step.keys)
+		if err != nil {
+			return nil, err
+		}
+
+		// Elements of slices can't be patched, so slices are patched as a whole.
+		tokens := t.tokens
+		for k, isIndex := range t.indexes {
+			if isIndex {
+				tokens = tokens[:k]
+				break
+			}
+		}
+
+		if len(tokens) == 0 {
+			return json.Marshal(mergeDiff(oldDoc, newDoc))
+		}
+
+		before, existed := lookupJSON(oldDoc, tokens)
+		after, exists := lookupJSON(newDoc, tokens)
+		switch {
+		case !exists && existed:
+			setJSON(patch, tokens, nil)
+		case !exists:
+		case !existed:
+			setJSON(patch, tokens, after)
+		default:
+			if diff, changed := diffJSON(before, after); changed {
+				setJSON(patch, tokens, diff)
+			}
+		}
+	}
+
+	return json.Marshal(patch)
+}
+
+// decodeJSONOf decodes the JSON encoding of v into doc.
+func decodeJSONOf(v interface{}, doc *interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, doc)
+}
+
+// diffJSON returns the merge patch from before to after, and whether they are different.
+func diffJSON(before, after interface{}) (interface{}, bool) {
+	_, isObject := before.(map[string]interface{})
+	if _, isAlsoObject := after.(map[string]interface{}); isObject && isAlsoObject {
+		diff := mergeDiff(before, after).(map[string]interface{})
+		return diff, len(diff) > 0
+	}
+
+	return after, !reflect.DeepEqual(before, after)
+}
+
+// mergeDiff returns the merge patch from the JSON value before to after.
+func mergeDiff(before, after interface{}) interface{} {
+	beforeObj, isObject := before.(map[string]interface{})
+	afterObj, isAlsoObject := after.(map[string]interface{})
+	if !isObject || !isAlsoObject {
+		return after
+	}
+
+	diff := map[string]interface{}{}
+	for key, value := range afterObj {
+		if old, found := beforeObj[key]; !found {
+			diff[key] = value
+		} else if sub, changed := diffJSON(old, value); changed {
+			diff[key] = sub
+		}
+	}
+
+	for key := range beforeObj {
+		if _, found := afterObj[key]; !found {
+			diff[key] = nil
+		}
+	}
+
+	return diff
+}
+
+// lookupJSON returns the value at tokens in the JSON document doc made of objects only.
+func lookupJSON(doc interface{}, tokens []string) (interface{}, bool) {
+	for _, token := range tokens {
+		obj, isObject := doc.(map[string]interface{})
+		if !isObject {
+			return nil, false
+		}
+
+		var found bool
+		if doc, found = obj[token]; !found {
+			return nil, false
+		}
+	}
+
+	return doc, true
+}
+
+// setJSON sets value at tokens in the JSON object obj, in which objects absent are created.
+func setJSON(obj map[string]interface{}, tokens []string, value interface{}) {
+	for _, token := range tokens[:len(tokens)-1] {
+		sub, isObject := obj[token].(map[string]interface{})
+		if !isObject {
+			sub = map[string]interface{}{}
+			obj[token] = sub
+		}
+
+		obj = sub
+	}
+
+	obj[tokens[len(tokens)-1]] = value
+}
+
+// ApplyMergePatch applies the JSON Merge Patch (RFC 7386) to the object dst points to, in which
+// names are those in json tags. Values patched are decoded from JSON into new values, so nothing
+// is shared between the patch and dst. The patch is applied to a deep copy of the object, including
+// unexported fields, which replaces the object on success, so dst is left untouched if the patch
+// can't be applied.
+func ApplyMergePatch(dst interface{}, patch []byte) error {
+	if dst == nil {
+		return ErrNilDestination
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return ErrNilDestination
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(patch, &doc); err != nil {
+		return err
+	}
+
+	p := &patcher{o: newOptions(nil)}
+	cpy, err := p.clone(v.Elem())
+	if err != nil {
+		return err
+	}
+
+	if err := p.merge(cpy, doc, ""); err != nil {
+		return err
+	}
+
+	v.Elem().Set(cpy)
+	return nil
+}
+
+// merge merges the JSON value patch into the settable v at the JSON pointer path.
+func (p *patcher) merge(v reflect.Value, patch interface{}, path string) error {
+	obj, isObject := patch.(map[string]interface{})
+	typ := v.Type()
+	if !isObject || reflect.PtrTo(typ).Implements(jsonUnmarshalerType) {
+		return p.decodeInto(v, patch, path)
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(typ.Elem()))
+		}
+
+		return p.merge(v.Elem(), patch, path)
+	case reflect.Interface:
+		// Values in interfaces are merged as JSON documents.
+		var doc interface{}
+		if !v.IsNil() {
+			if err := decodeJSONOf(v.Interface(), &doc); err != nil {
+				return &PatchError{Op: "merge", Path: path, Reason: err.Error()}
+			}
+		}
+
+		return p.decodeInto(v, applyMergeJSON(doc, obj), path)
+	case reflect.Struct, reflect.Map:
+		for name, value := range obj {
+			w := pathWalker{resolver: JSONTagNames, pointer: true, promote: value != nil}
+			s, err := w.step(v, typ, name, 0)
+			member := path + pointerOf([]string{name})
+			switch {
+			case err != nil:
+				return &PatchError{Op: "merge", Path: path, Reason: err.Error()}
+			case typ.Kind() == reflect.Map && value == nil:
+				if !v.IsNil() {
+					v.SetMapIndex(s.key, reflect.Value{})
+				}
+			case typ.Kind() == reflect.Map:
+				err = updateEntry(v, s.key, func(elem reflect.Value) error {
+					return p.merge(elem, value, member)
+				})
+			case !s.value.IsValid():
+				// The field is promoted from a nil embedded pointer, so it is null already.
+			case value == nil:
+				s.value.Set(reflect.Zero(s.typ))
+			default:
+				err = p.merge(s.value, value, member)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	default:
+		return p.decodeInto(v, patch, path)
+	}
+}
+
+// decodeInto decodes the JSON value into a new value which then replaces v.
+func (p *patcher) decodeInto(v reflect.Value, value interface{}, path string) error {
+	if obj, isObject := value.(map[string]interface{}); isObject {
+		// Members which are null are removed by patches.
+		value = applyMergeJSON(nil, obj)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return &PatchError{Op: "merge", Path: path, Reason: err.Error()}
+	}
+
+	decoded := reflect.New(v.Type())
+	if err = json.Unmarshal(data, decoded.Interface()); err != nil {
+		return &PatchError{Op: "merge", Path: path, Reason: err.Error()}
+	}
+
+	v.Set(decoded.Elem())
+	return nil
+}
+
+// applyMergeJSON applies the merge patch to the JSON value doc as is defined in RFC 7386.
+func applyMergeJSON(doc interface{}, patch map[string]interface{}) interface{} {
+	obj, isObject := doc.(map[string]interface{})
+	if !isObject {
+		obj = map[string]interface{}{}
+	}
+
+	for name, value := range patch {
+		if value == nil {
+			delete(obj, name)
+		} else if sub, isObject := value.(map[string]interface{}); isObject {
+			obj[name] = applyMergeJSON(obj[name], sub)
+		} else {
+			obj[name] = value
+		}
+	}
+
+	return obj
+}
@@ -0,0 +1,72 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	three := 3
+	old := &patchedObject{Name: "A", Spec: &patchedSpec{
+		Image:  "v1",
+		Labels: map[string]string{"app": "A", "tier": "web"},
+		Items:  []simpleStruct{{FieldA: "A"}, {FieldA: "B"}},
+	}}
+	new := &patchedObject{Name: "B", Spec: &patchedSpec{
+		Replicas: &three,
+		Image:    "v2",
+		Labels:   map[string]string{"app": "A", "version": "2"},
+		Items:    []simpleStruct{{FieldA: "A", FieldB: 1}, {FieldA: "B"}},
+	}}
+
+	patch, err := deepcopy.MergePatch(old, new, "Spec.Replicas", "Spec.Labels", "Spec.Items[0].FieldB")
+	assert.NilError(t, err)
+	assert.Equal(t, string(patch), `{"spec":{"items":[{"FieldA":"A","FieldB":1,"FieldC":0},`+
+		`{"FieldA":"B","FieldB":0,"FieldC":0}],"labels":{"tier":null,"version":"2"},"replicas":3}}`)
+	assert.Equal(t, old.Spec.Replicas, (*int)(nil))
+
+	assert.NilError(t, deepcopy.ApplyMergePatch(old, patch))
+	assert.Equal(t, old.Name, "A")
+	assert.Equal(t, old.Spec.Image, "v1")
+	old.Name, old.Spec.Image = "B", "v2"
+	assert.DeepEqual(t, old, new)
+
+	new.Spec = nil
+	patch, err = deepcopy.MergePatch(old, new, "Name", "Spec")
+	assert.NilError(t, err)
+	assert.Equal(t, string(patch), `{"spec":null}`)
+
+	patch, err = deepcopy.MergePatch(&patchedObject{}, old, "Spec.Image")
+	assert.NilError(t, err)
+	assert.Equal(t, string(patch), `{"spec":{"image":"v2"}}`)
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	labels := map[string]string{"app": "A"}
+	obj := &patchedObject{Name: "A", Cache: "cached", Spec: &patchedSpec{Labels: labels}}
+	assert.NilError(t, deepcopy.ApplyMergePatch(obj, []byte(
+		`{"spec":{"labels":{"app":null,"tier":"web"},"items":[{"FieldA":"A"}],"replicas":1}}`)))
+	three := 1
+	assert.DeepEqual(t, obj, &patchedObject{Name: "A", Cache: "cached", Spec: &patchedSpec{
+		Replicas: &three,
+		Labels:   map[string]string{"tier": "web"},
+		Items:    []simpleStruct{{FieldA: "A"}},
+	}})
+
+	err := deepcopy.ApplyMergePatch(obj, []byte(`{"name":"B","spec":{"replicas":"many"}}`))
+	assert.ErrorContains(t, err, `patch operation "merge" at "/spec/replicas"`)
+	assert.Equal(t, obj.Name, "A")
+	err = deepcopy.ApplyMergePatch(obj, []byte(`{"status":{}}`))
+	assert.ErrorContains(t, err, "unknown field status")
+
+	assert.NilError(t, deepcopy.ApplyMergePatch(obj, []byte(`{"spec":null}`)))
+	assert.Assert(t, obj.Spec == nil)
+
+	revised := &revisedObject{Name: "A", Tags: []string{"a"}, revision: 1}
+	assert.NilError(t, deepcopy.ApplyMergePatch(revised, []byte(`{"name":"B"}`)))
+	assert.Equal(t, revised.Name, "B")
+	assert.Equal(t, revised.revision, 1)
+	assert.DeepEqual(t, revised.Tags, []string{"a"})
+	assert.DeepEqual(t, labels, map[string]string{"app": "A"})
+}
@@ -0,0 +1,156 @@
+package deepcopy
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// NameResolver tells names of fields of structures in field paths. Fields resolved are cached per
+// resolver, so resolvers must always resolve the same name for a field.
+type NameResolver interface {
+	// FieldName returns the name of field in field paths, which is blank if the field can't be
+	// selected. inline is true if fields of the field are promoted to the structure it belongs to.
+	FieldName(field reflect.StructField) (name string, inline bool)
+}
+
+// TagNames resolves names of fields from their struct tags with the key it stands for, e.g.
+// `json:"name,omitempty"`. Fields without the tag are named by their Go names, and embedded
+// structures without a name in the tag are inlined. A tag `json:",inline"` inlines the field,
+// while `json:"-"` makes the field unable to be selected. For the key "protobuf", the name is
+// the one after "name=", e.g. `protobuf:"bytes,1,opt,name=metadata"`.
+type TagNames string
+
+const (
+	JSONTagNames     TagNames = "json"
+	YAMLTagNames     TagNames = "yaml"
+	ProtobufTagNames TagNames = "protobuf"
+)
+
+func (t TagNames) FieldName(field reflect.StructField) (name string, inline bool) {
+	tag := field.Tag.Get(string(t))
+	if tag == "-" {
+		return "", false
+	}
+
+	opts := strings.Split(tag, ",")
+	if t == ProtobufTagNames {
+		for _, opt := range opts {
+			if strings.HasPrefix(opt, "name=") {
+				name = strings.TrimPrefix(opt, "name=")
+			}
+		}
+	} else {
+		name = opts[0]
+		for _, opt := range opts[1:] {
+			if opt == "inline" {
+				return "", true
+			}
+		}
+	}
+
+	if len(name) > 0 {
+		return name, false
+	}
+
+	typ := field.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if field.Anonymous && typ.Kind() == reflect.Struct {
+		return "", true
+	}
+
+	return field.Name, false
+}
+
+// resolveField looks for the field named name by resolver in the structure typ. Fields inlined
+// are searched in breadth-first order like promoted fields of embedded structures, so the
+// shallowest one wins. It returns nil if no field found.
+func resolveField(typ reflect.Type, name string, resolver NameResolver) *fieldPlan {
+	type candidate struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	level := []candidate{{typ: typ}}
+	visited := map[reflect.Type]bool{}
+	for len(level) > 0 {
+		var next []candidate
+		for _, c := range level {
+			if visited[c.typ] {
+				continue
+			}
+
+			visited[c.typ] = true
+			for i := 0; i < c.typ.NumField(); i++ {
+				field := c.typ.Field(i)
+				// Exported fields of unexported embedded structures are still promoted.
+				if field.PkgPath != "" && !field.Anonymous {
+					continue
+				}
+
+				fieldName, inline := resolver.FieldName(field)
+				index := append(c.index[:len(c.index):len(c.index)], i)
+				if inline {
+					fieldTyp := field.Type
+					if fieldTyp.Kind() == reflect.Ptr && field.PkgPath == "" {
+						fieldTyp = fieldTyp.Elem()
+					}
+
+					if fieldTyp.Kind() == reflect.Struct {
+						next = append(next, candidate{typ: fieldTyp, index: index})
+					}
+
+					continue
+				}
+
+				if fieldName == name && field.PkgPath == "" {
+					return &fieldPlan{index: index, mode: parseCopyMode(field.Tag)}
+				}
+			}
+		}
+
+		level = next
+	}
+
+	return nil
+}
+
+// ownCache returns resolver as is if it is comparable, so that fields it resolves are cached in
+// plans, or wraps it into a cachingResolver otherwise.
+func ownCache(resolver NameResolver) NameResolver {
+	if resolver == nil || reflect.TypeOf(resolver).Comparable() {
+		return resolver
+	}
+
+	return &cachingResolver{NameResolver: resolver}
+}
+
+// cachingResolver is a resolver which can't be a key of caches in plans, along with its own cache
+// of fields it resolves.
+type cachingResolver struct {
+	NameResolver
+	// fields caches fields found by name. It is keyed by typeField and valued by *fieldPlan, which
+	// is nil if no field found.
+	fields sync.Map
+}
+
+// typeField is the key of cachingResolver.fields.
+type typeField struct {
+	typ  reflect.Type
+	name string
+}
+
+// field is like typePlan.field but caches fields in r.
+func (r *cachingResolver) field(typ reflect.Type, name string) *fieldPlan {
+	key := typeField{typ, name}
+	if f, found := r.fields.Load(key); found {
+		return f.(*fieldPlan)
+	}
+
+	f := resolveField(typ, name, r.NameResolver)
+	r.fields.Store(key, f)
+	return f
+}
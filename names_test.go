@@ -0,0 +1,152 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type typeMeta struct {
+	Kind string `json:"kind,omitempty" yaml:"kind"`
+}
+
+type ObjectMeta struct {
+	Name   string            `json:"name,omitempty" yaml:"name"`
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels"`
+}
+
+type taggedSpec struct {
+	Replicas *int   `json:"replicas,omitempty" yaml:"replicas"`
+	NodeName string `json:"nodeName,omitempty" yaml:"node"`
+	Ignored  string `json:"-"`
+}
+
+type taggedObject struct {
+	typeMeta   `json:",inline" yaml:",inline"`
+	ObjectMeta `json:"metadata,omitempty" yaml:"metadata"`
+	Spec       taggedSpec `json:"spec" yaml:"spec"`
+	Status     string
+}
+
+func TestFieldPathsInJSONNames(t *testing.T) {
+	replicas := 3
+	src := taggedObject{
+		typeMeta:   typeMeta{Kind: "Object"},
+		ObjectMeta: ObjectMeta{Name: "A", Labels: map[string]string{"app": "A"}},
+		Spec:       taggedSpec{Replicas: &replicas, NodeName: "node", Ignored: "ignored"},
+		Status:     "Running",
+	}
+
+	var dst taggedObject
+	copied, err := deepcopy.PartialWith(&dst, &src,
+		[]string{"kind", "metadata.labels.app", "spec.replicas", "Status"},
+		deepcopy.WithNameResolver(deepcopy.JSONTagNames))
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.Equal(t, dst.Kind, "Object")
+	assert.Equal(t, dst.Name, "")
+	assert.DeepEqual(t, dst.Labels, src.Labels)
+	assert.Equal(t, *dst.Spec.Replicas, replicas)
+	assert.Equal(t, dst.Spec.NodeName, "")
+	assert.Equal(t, dst.Status, src.Status)
+
+	copied, err = deepcopy.PartialWith(&dst, &src, []string{"spec.Ignored", "Spec.NodeName"},
+		deepcopy.WithNameResolver(deepcopy.JSONTagNames))
+	assert.NilError(t, err)
+	assert.Assert(t, !copied)
+
+	copied, err = deepcopy.OnChangeWith(&dst, &src, []string{"metadata.name", "spec.node"},
+		deepcopy.WithNameResolver(deepcopy.TagNames("yaml")))
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.Equal(t, dst.Name, src.Name)
+	assert.Equal(t, dst.Spec.NodeName, src.Spec.NodeName)
+
+	replicator, err := deepcopy.NewPartialReplicatorWith([]string{"metadata"},
+		deepcopy.WithNameResolver(deepcopy.JSONTagNames))
+	assert.NilError(t, err)
+	dst = taggedObject{}
+	assert.Assert(t, replicator.Copy(&dst, &src))
+	assert.DeepEqual(t, dst.Labels, src.Labels)
+}
+
+// lowerNames names fields by their Go names in lower case. It is backed by a function, so it
+// can't be compared.
+type lowerNames func(string) string
+
+func (l lowerNames) FieldName(field reflect.StructField) (string, bool) {
+	return l(field.Name), false
+}
+
+func TestNonComparableNameResolver(t *testing.T) {
+	src := simpleStruct{FieldA: "A", FieldB: 1}
+	var dst simpleStruct
+	for i := 0; i < 2; i++ {
+		copied, err := deepcopy.PartialWith(&dst, &src, []string{"fielda"},
+			deepcopy.WithNameResolver(lowerNames(strings.ToLower)))
+		assert.NilError(t, err)
+		assert.Assert(t, copied)
+		assert.DeepEqual(t, dst, simpleStruct{FieldA: "A"})
+	}
+
+	mask, err := deepcopy.FromFieldMask([]string{"fieldb"}, lowerNames(strings.ToLower))
+	assert.NilError(t, err)
+	copied, err := deepcopy.OnChangeMask(&dst, &src, mask)
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.Equal(t, dst.FieldB, 1)
+}
+
+type InlineMeta struct {
+	Kind    string `json:"kind,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type inlinedObject struct {
+	*InlineMeta `json:",inline"`
+	Name        string `json:"name"`
+}
+
+func TestFieldsPromotedFromEmbeddedPointers(t *testing.T) {
+	src := inlinedObject{InlineMeta: &InlineMeta{Kind: "Object", Version: "v1"}, Name: "A"}
+	var dst inlinedObject
+	assert.Assert(t, deepcopy.Partial(&dst, &src, "Kind"))
+	assert.DeepEqual(t, dst, inlinedObject{InlineMeta: &InlineMeta{Kind: "Object"}})
+
+	dst = inlinedObject{}
+	copied, err := deepcopy.PartialWith(&dst, &src, []string{"version", "name"},
+		deepcopy.WithNameResolver(deepcopy.JSONTagNames))
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.DeepEqual(t, dst, inlinedObject{InlineMeta: &InlineMeta{Version: "v1"}, Name: "A"})
+
+	copied, err = deepcopy.PartialE(&dst, &inlinedObject{Name: "B"}, "Kind", "Name")
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.DeepEqual(t, dst, inlinedObject{Name: "B"})
+
+	copied, err = deepcopy.PartialWith(&dst, &inlinedObject{Name: "C"}, []string{"kind", "name"},
+		deepcopy.WithNameResolver(deepcopy.JSONTagNames), deepcopy.WithMerge())
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.DeepEqual(t, dst, inlinedObject{Name: "C"})
+
+	dst = inlinedObject{}
+	copied, err = deepcopy.OnChangeE(&dst, &inlinedObject{}, "Kind")
+	assert.NilError(t, err)
+	assert.Assert(t, !copied)
+	assert.Assert(t, dst.InlineMeta == nil)
+
+	changes, err := deepcopy.OnChangeReportWith(&dst, &src, []string{"kind"},
+		deepcopy.WithNameResolver(deepcopy.JSONTagNames))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes.Paths(), []string{"kind"})
+	assert.DeepEqual(t, dst.InlineMeta, &InlineMeta{Kind: "Object"})
+
+	copied, err = deepcopy.OnChangeE(&dst, &inlinedObject{}, "Kind", "Version")
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.DeepEqual(t, dst.InlineMeta, &InlineMeta{})
+}
@@ -0,0 +1,233 @@
+package deepcopy
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Option configures how fields are selected and copied.
+type Option func(*options)
+
+type options struct {
+	resolver NameResolver
+	tracer   Tracer
+	merge    bool
+	// unexported is true if unexported fields are copied as well.
+	unexported bool
+	copier     *Copier
+	uncopyable UncopyablePolicy
+	equalOpts  []EqualOption
+	// equality is built from equalOpts when objects are compared.
+	equality *equalOptions
+	// mergeKeys are merge keys declared for slices in the order of declaration.
+	mergeKeys []mergeKeys
+	// conflictResolver resolves conflicts in three-way merges.
+	conflictResolver ConflictResolver
+	// fieldHooks are hooks of OnChange in the order of declaration.
+	fieldHooks []fieldHook
+	// dryRun is true if OnChange leaves the destination untouched.
+	dryRun bool
+}
+
+// fieldHook is a FieldHook along with the tree parsed from its path, in which the node at the
+// path is selected.
+type fieldHook struct {
+	path      string
+	hierarchy tree
+	hook      FieldHook
+}
+
+type mergeKeys struct {
+	path string
+	keys []string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		tracer: &traceNothing{},
+		copier: defaultCopier,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithNameResolver resolves segments of field paths via resolver instead of Go field names, e.g.
+// WithNameResolver(JSONTagNames) makes paths like "metadata.labels" select ObjectMeta.Labels.
+func WithNameResolver(resolver NameResolver) Option {
+	return func(o *options) {
+		o.resolver = ownCache(resolver)
+	}
+}
+
+// WithTracer makes OnChange print how objects are walked through via tracer.
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithMerge makes partial replicators write fields selected into the destination in place, so
+// fields not selected are left as they are, rather than replacing the destination with a copy
+// only containing fields selected.
+func WithMerge() Option {
+	return func(o *options) {
+		o.merge = true
+	}
+}
+
+// WithMergeKeys makes elements of the slice at path matched by values of the fields keys rather
+// than their indexes, e.g. WithMergeKeys("Spec.Containers", "Name"). Indexes and wildcards in path
+// are ignored, so "Spec.Containers.Ports" and "Spec.Containers[*].Ports" are the same. Elements
+// matched are kept in their order in the destination. Elements absent from the destination are
+// appended, and if every element is selected, those absent from the source are removed. Slices in
+// values selected as a whole are matched as well, in which case the values are compared and copied
+// field by field. OnChange and typed replicators fail if path isn't a slice of structures with keys.
+func WithMergeKeys(path string, keys ...string) Option {
+	return func(o *options) {
+		o.mergeKeys = append(o.mergeKeys, mergeKeys{path: path, keys: keys})
+	}
+}
+
+// IncludeUnexported makes copies include unexported fields as well as exported ones,
+// which are read and written via unsafe. Unexported fields are copied in the same way as exported
+// ones, so pointers, slices and maps in them are duplicated, shared references and cycles are
+// kept, and struct tags are honored. Other states, e.g. a locked sync.Mutex, are copied as they are,
+// so the copy is only safe to use if the source isn't changed during copying and its type doesn't
+// depend on addresses of its values other than pointers to itself. OnChange compares unexported
+// fields as well, like CompareUnexported does.
+func IncludeUnexported() Option {
+	return func(o *options) {
+		o.unexported = true
+	}
+}
+
+// WithEqualOptions makes OnChange compare values with opts to tell whether they are changed.
+// Paths in IgnorePaths are relative to the objects passed to OnChange.
+func WithEqualOptions(opts ...EqualOption) Option {
+	return func(o *options) {
+		o.equalOpts = append(o.equalOpts, opts...)
+	}
+}
+
+// WithCopier makes copies use copiers registered in copier instead of those registered globally
+// via RegisterCopier.
+func WithCopier(copier *Copier) Option {
+	return func(o *options) {
+		o.copier = copier
+	}
+}
+
+// UncopyablePolicy tells what copies do with channels and functions, which can't be copied.
+type UncopyablePolicy int
+
+const (
+	// ShareUncopyable assigns channels and functions as-is, so they are shared between the source
+	// and the copy. It is the default policy.
+	ShareUncopyable UncopyablePolicy = iota
+	// NilUncopyable leaves channels and functions nil in the copy.
+	NilUncopyable
+	// RejectUncopyable fails copies with an UncopyableError if any channel or function is not nil.
+	RejectUncopyable
+)
+
+// WithUncopyablePolicy makes copies handle channels and functions according to policy.
+func WithUncopyablePolicy(policy UncopyablePolicy) Option {
+	return func(o *options) {
+		o.uncopyable = policy
+	}
+}
+
+// DryRun makes OnChange compare objects and report changes as usual but leave the destination
+// untouched. Instead of copying the destination first, pointers, slices and maps OnChange walks
+// through are copied shallowly before being changed, so values unchanged are shared rather than
+// copied. OnChangePreview returns the object the destination would become. Hooks set by OnField
+// aren't called, so changes they would veto are reported as well.
+func DryRun() Option {
+	return func(o *options) {
+		o.dryRun = true
+	}
+}
+
+// FieldHook is called by OnChange before the value at path is changed from old to new, in which
+// either is invalid if the value is absent. Returning ErrVetoChange keeps the value as it is,
+// while other errors stop OnChange.
+type FieldHook func(path string, old, new reflect.Value) error
+
+// OnField makes OnChange call hook before changing the value at path or any value in it. Like
+// IgnorePaths, elements of slices are selected by their indexes in path, or all of them if no
+// index is given. Vetoing an element appended to a slice stops appending the rest, while vetoing
+// an element removed keeps the ones before it. Structures, slices and maps selected as a whole
+// with hooks at paths in them are copied part by part, so that only the parts vetoed are kept,
+// unless they are absent from either side, or compared, copied or hooked as a whole. Hooks aren't
+// called in dry runs, nor by DiffPatch, MergePatch and ThreeWayMerge, which change nothing.
+func OnField(path string, hook FieldHook) Option {
+	return func(o *options) {
+		o.fieldHooks = append(o.fieldHooks, fieldHook{path: path, hook: hook})
+	}
+}
+
+// parseFieldHooks parses paths of hooks into trees.
+func (o *options) parseFieldHooks() (err error) {
+	for i := range o.fieldHooks {
+		if o.fieldHooks[i].hierarchy, err = fieldsToTree([]string{o.fieldHooks[i].path}); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// allowChange calls hooks of the value at the path made of keys before it is changed from old to
+// new. It returns ErrVetoChange if any hook vetoes the change.
+func (o *options) allowChange(keys []string, old, new reflect.Value) error {
+	for _, h := range o.fieldHooks {
+		if sub, found := h.hierarchy.descend(keys); !found || !sub.selected {
+			continue
+		}
+
+		if err := h.hook(joinKeys(keys), old, new); errors.Is(err, ErrVetoChange) {
+			return ErrVetoChange
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hooksAt tells whether any hook is called before the value at the path made of keys is changed.
+func (o *options) hooksAt(keys []string) bool {
+	for _, h := range o.fieldHooks {
+		if sub, found := h.hierarchy.descend(keys); found && sub.selected {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hooksBelow tells whether any hook is called before values in the value at the path made of keys
+// are changed, but not the value itself.
+func (o *options) hooksBelow(keys []string) bool {
+	for _, h := range o.fieldHooks {
+		if sub, found := h.hierarchy.descend(keys); found && !sub.selected {
+			return true
+		}
+	}
+
+	return false
+}
+
+// equalAt tells whether a and b at the path made of keys are equal according to o.equality.
+func (o *options) equalAt(keys []string, a, b reflect.Value) bool {
+	var rules *tree
+	if sub, found := o.equality.rules.descend(keys); found {
+		rules = &sub
+	}
+
+	return newEqualState(o.equality).equal(a, b, rules)
+}
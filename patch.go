@@ -0,0 +1,612 @@
+package deepcopy
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is an operation of a JSON Patch (RFC 6902).
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// DiffPatch returns the JSON Patch (RFC 6902) which makes the fields selected of old the same as
+// those of new, i.e. what OnChange would change in old. Both must be pointers of the same type.
+// Paths of operations are JSON pointers made of names in json tags, while fields selected are
+// still Go field names. old is left untouched.
+func DiffPatch(old, new interface{}, fieldsSelected ...string) ([]byte, error) {
+	return DiffPatchWith(old, new, fieldsSelected)
+}
+
+// DiffPatchWith is like DiffPatch but configured by opts.
+func DiffPatchWith(old, new interface{}, fieldsSelected []string, opts ...Option) ([]byte, error) {
+	ops, err := diffPatch(old, new, fieldsSelected, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(ops)
+}
+
+func diffPatch(old, new interface{}, fieldsSelected []string, o *options) ([]PatchOperation, error) {
+	if err := checkObjects(old, new); err != nil {
+		return nil, err
+	}
+
+	o.dryRun = true
+	rec, err := recordChanges(old, new, fieldsSelected, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Operations are applied in order, so changes to elements of a slice come first, then elements
+	// are removed from the last one, and new ones are inserted at last.
+	order := make([]int, len(rec.changes))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return rec.steps[order[i]].before(rec.steps[order[j]])
+	})
+
+	d := &differ{old: reflect.ValueOf(old).Elem(), resolver: o.resolver, created: make(map[string]bool)}
+	ops := make([]PatchOperation, 0, len(order))
+	for _, i := range order {
+		op, ok, err := d.operation(rec.changes[i], rec.steps[i])
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			ops = append(ops, op)
+		}
+	}
+
+	return ops, nil
+}
+
+// before tells whether the change at s is made before the one at other in a patch.
+func (s changeStep) before(other changeStep) bool {
+	for k := 0; k < len(s.keys) && k < len(other.keys); k++ {
+		a, b := s.keys[k], other.keys[k]
+		if a == b {
+			continue
+		}
+
+		i, isIndex := indexOfKey(a)
+		j, otherIsIndex := indexOfKey(b)
+		if !isIndex || !otherIsIndex {
+			return a < b
+		}
+
+		if how, otherHow := s.resizeAt(k), other.resizeAt(k); how != otherHow {
+			return how < otherHow
+		} else if how == resizeRemove {
+			return i > j
+		}
+
+		return i < j
+	}
+
+	return len(s.keys) < len(other.keys)
+}
+
+// resizeAt returns how the change resizes the slice its kth key is applied to.
+func (s changeStep) resizeAt(k int) resize {
+	if k == len(s.keys)-1 {
+		return s.resize
+	}
+
+	return resizeNone
+}
+
+// indexOfKey returns the index in key if it selects an element of a slice, e.g. "[1]".
+func indexOfKey(key string) (int, bool) {
+	if !strings.HasPrefix(key, "[") || strings.HasPrefix(key, `["`) {
+		return 0, false
+	}
+
+	index, err := strconv.Atoi(strings.Trim(key, "[]"))
+	return index, err == nil
+}
+
+// differ makes patch operations out of changes to old.
+type differ struct {
+	old      reflect.Value
+	resolver NameResolver
+	// created are pointers to values absent from old but added by operations made so far.
+	created map[string]bool
+}
+
+// target is where a change is made in the JSON document of old.
+type target struct {
+	tokens []string
+	// indexes tell which tokens are indexes of slices.
+	indexes []bool
+	// absentAt is the position of the first token absent from the document, or -1 if none.
+	absentAt int
+	// omitEmpty is true if the value is a field omitted from the document if it is empty.
+	omitEmpty bool
+}
+
+func (d *differ) operation(change Change, step changeStep) (op PatchOperation, ok bool, err error) {
+	t, err := d.target(step.keys)
+	if err != nil {
+		return
+	}
+
+	last := len(t.tokens) - 1
+	removed := change.New == nil || t.omitEmpty && isEmptyJSON(reflect.ValueOf(change.New))
+	switch {
+	case removed && t.absentAt >= 0:
+		return
+	case removed:
+		return PatchOperation{Op: "remove", Path: pointerOf(t.tokens)}, true, nil
+	}
+
+	value, err := json.Marshal(change.New)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case t.absentAt >= 0 && t.absentAt < last:
+		// The parent is absent as well, so it is added along with the value.
+		for k := last; k > t.absentAt; k-- {
+			if t.indexes[k] {
+				value = append(append([]byte("["), value...), ']')
+			} else {
+				key, _ := json.Marshal(t.tokens[k])
+				value = append(append(append(append([]byte("{"), key...), ':'), value...), '}')
+			}
+		}
+
+		for k := t.absentAt; k <= last; k++ {
+			d.created[pointerOf(t.tokens[:k+1])] = true
+		}
+
+		return PatchOperation{Op: "add", Path: pointerOf(t.tokens[:t.absentAt+1]), Value: value}, true, nil
+	case t.absentAt == last || step.resize == resizeInsert:
+		d.created[pointerOf(t.tokens)] = true
+		return PatchOperation{Op: "add", Path: pointerOf(t.tokens), Value: value}, true, nil
+	default:
+		return PatchOperation{Op: "replace", Path: pointerOf(t.tokens), Value: value}, true, nil
+	}
+}
+
+// target translates keys of the path of a change into tokens of the JSON pointer, and tells what
+// in the path is absent from the JSON document of old.
+func (d *differ) target(keys []string) (t target, err error) {
+	t.absentAt = -1
+	w := pathWalker{resolver: d.resolver}
+	v, typ := d.old, d.old.Type()
+	for n, key := range keys {
+		var ok bool
+		if v, typ, ok = indirectPath(v, typ); !ok {
+			err = &PathError{Path: joinKeys(keys), Index: n, Reason: "goes through a nil interface"}
+			return
+		}
+
+		var s pathStep
+		if s, err = w.step(v, typ, key, n); err != nil {
+			err = err.(*walkError).pathError(keys)
+			return
+		}
+
+		switch typ.Kind() {
+		case reflect.Struct:
+			for m, i := range s.field.index {
+				if m > 0 && typ.Kind() == reflect.Ptr {
+					// The field is promoted from an embedded pointer.
+					typ = typ.Elem()
+				}
+
+				field := typ.Field(i)
+				typ = field.Type
+				name, inline := JSONTagNames.FieldName(field)
+				if inline {
+					continue
+				}
+
+				if name == "" {
+					err = &PathError{Path: joinKeys(keys), Index: n, Reason: "contains a field absent from JSON"}
+					return
+				}
+
+				t.tokens, t.indexes = append(t.tokens, name), append(t.indexes, false)
+				t.omitEmpty = strings.Contains(field.Tag.Get("json"), ",omitempty")
+			}
+		case reflect.Map:
+			t.tokens, t.indexes = append(t.tokens, fmtMapKey(s.key)), append(t.indexes, false)
+			t.omitEmpty = false
+		default:
+			t.tokens, t.indexes = append(t.tokens, strconv.Itoa(s.index)), append(t.indexes, true)
+			t.omitEmpty = false
+		}
+
+		v, typ = s.value, s.typ
+		present := v.IsValid() && !(t.omitEmpty && isEmptyJSON(v))
+		if n < len(keys)-1 && present {
+			// Values in the path to the one changed must not be null.
+			present = !isNullJSON(v)
+		}
+
+		if !present && t.absentAt < 0 && !d.created[pointerOf(t.tokens)] {
+			t.absentAt = len(t.tokens) - 1
+		}
+	}
+
+	return
+}
+
+// fmtMapKey formats key of a map as a key of JSON objects.
+func fmtMapKey(key reflect.Value) string {
+	switch key.Kind() {
+	case reflect.String:
+		return key.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10)
+	default:
+		return ""
+	}
+}
+
+// isEmptyJSON tells whether v is empty in the way of the json option "omitempty".
+func isEmptyJSON(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// isNullJSON tells whether v is encoded as null.
+func isNullJSON(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// pointerOf joins tokens into a JSON pointer (RFC 6901).
+func pointerOf(tokens []string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteByte('/')
+		b.WriteString(pointerEscaper.Replace(token))
+	}
+
+	return b.String()
+}
+
+// parsePointer splits the JSON pointer into tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, &PatchError{Reason: "has an invalid JSON pointer " + strconv.Quote(pointer)}
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+
+	return tokens, nil
+}
+
+// ApplyPatch applies the JSON Patch (RFC 6902) to the object dst points to, in which JSON
+// pointers are made of names in json tags. Values are decoded from JSON into types of the values
+// they replace, while values moved or copied are deeply copied. Operations are applied to a deep
+// copy of the object, including unexported fields, which replaces the object if all of them
+// succeed, so dst is left untouched if any operation fails, including tests.
+func ApplyPatch(dst interface{}, patch []byte) error {
+	if dst == nil {
+		return ErrNilDestination
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return ErrNilDestination
+	}
+
+	var ops []PatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return err
+	}
+
+	p := &patcher{o: newOptions(nil)}
+	cpy, err := p.clone(v.Elem())
+	if err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		if err := p.apply(cpy, op); err != nil {
+			return err
+		}
+	}
+
+	v.Elem().Set(cpy)
+	return nil
+}
+
+// patchMode is what a patcher does to the value at the end of a path.
+type patchMode int
+
+const (
+	patchGet patchMode = iota
+	patchAdd
+	patchReplace
+	patchRemove
+)
+
+// valueFunc makes the value of typ to be put by a patcher.
+type valueFunc func(typ reflect.Type) (reflect.Value, error)
+
+// patcher applies patch operations to Go values.
+type patcher struct {
+	o *options
+}
+
+func (p *patcher) copy(src, dst reflect.Value) error {
+	return copyRecursive(src, dst, p.o)
+}
+
+// clone returns a deep copy of v including unexported fields. Patches are applied to the copy,
+// which then replaces v, so that v isn't patched partially on failure.
+func (p *patcher) clone(v reflect.Value) (reflect.Value, error) {
+	cpy := reflect.New(v.Type()).Elem()
+	return cpy, copyRecursive(v, cpy, newOptions([]Option{IncludeUnexported()}))
+}
+
+func (p *patcher) apply(v reflect.Value, op PatchOperation) error {
+	fail := func(err error) error {
+		if patchErr, ok := err.(*PatchError); ok {
+			patchErr.Op, patchErr.Path = op.Op, op.Path
+			return patchErr
+		}
+
+		return &PatchError{Op: op.Op, Path: op.Path, Reason: err.Error()}
+	}
+
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return fail(err)
+	}
+
+	decode := func(typ reflect.Type) (reflect.Value, error) {
+		if op.Value == nil {
+			return reflect.Value{}, &PatchError{Reason: "misses the value"}
+		}
+
+		value := reflect.New(typ)
+		err := json.Unmarshal(op.Value, value.Interface())
+		return value.Elem(), err
+	}
+
+	switch op.Op {
+	case "add", "replace":
+		mode := patchAdd
+		if op.Op == "replace" {
+			mode = patchReplace
+		}
+
+		_, err = p.walk(v, tokens, mode, decode)
+	case "remove":
+		_, err = p.walk(v, tokens, patchRemove, nil)
+	case "move", "copy":
+		var from []string
+		if from, err = parsePointer(op.From); err != nil {
+			return fail(err)
+		}
+
+		mode := patchGet
+		if op.Op == "move" {
+			mode = patchRemove
+		}
+
+		var value reflect.Value
+		if value, err = p.walk(v, from, mode, nil); err != nil {
+			return fail(err)
+		}
+
+		_, err = p.walk(v, tokens, patchAdd, func(typ reflect.Type) (reflect.Value, error) {
+			return p.convert(value, typ)
+		})
+	case "test":
+		var value, expected reflect.Value
+		if value, err = p.walk(v, tokens, patchGet, nil); err != nil {
+			return fail(err)
+		}
+
+		if expected, err = decode(value.Type()); err != nil {
+			return fail(err)
+		}
+
+		if !equalJSON(value, expected) {
+			err = &PatchError{Reason: "fails the test"}
+		}
+	default:
+		err = &PatchError{Reason: "is an unknown operation"}
+	}
+
+	if err != nil {
+		return fail(err)
+	}
+
+	return nil
+}
+
+// convert deeply copies value into a new value of typ, via JSON if value isn't of typ.
+func (p *patcher) convert(value reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	cpy := reflect.New(typ).Elem()
+	if value.Type() == typ {
+		return cpy, p.copy(value, cpy)
+	}
+
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		return cpy, err
+	}
+
+	return cpy, json.Unmarshal(data, cpy.Addr().Interface())
+}
+
+// equalJSON tells whether a and b are encoded as the same JSON value.
+func equalJSON(a, b reflect.Value) bool {
+	var decoded [2]interface{}
+	for i, v := range []reflect.Value{a, b} {
+		data, err := json.Marshal(v.Interface())
+		if err != nil || json.Unmarshal(data, &decoded[i]) != nil {
+			return false
+		}
+	}
+
+	return reflect.DeepEqual(decoded[0], decoded[1])
+}
+
+// walk walks the settable v along tokens and then does mode to the value at the end, which is
+// returned. Values put are made by value.
+func (p *patcher) walk(v reflect.Value, tokens []string, mode patchMode, value valueFunc) (found reflect.Value, err error) {
+	if len(tokens) == 0 {
+		switch mode {
+		case patchGet:
+			return v, nil
+		case patchRemove:
+			return reflect.Value{}, &PatchError{Reason: "can't remove the whole object"}
+		}
+
+		put, err := value(v.Type())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		v.Set(put)
+		return put, nil
+	}
+
+	w := pathWalker{resolver: JSONTagNames, pointer: true, promote: mode == patchAdd || mode == patchReplace}
+	err = w.update(v, tokens, func(container reflect.Value, token string, s pathStep) (err error) {
+		found, err = p.visit(container, token, s, mode, value)
+		return
+	})
+
+	return
+}
+
+// visit does mode to the value at token in container, a structure, map, slice or array, where
+// token leads to s.
+func (p *patcher) visit(container reflect.Value, token string, s pathStep, mode patchMode, value valueFunc) (
+	reflect.Value, error) {
+	switch container.Kind() {
+	case reflect.Struct:
+		switch {
+		case !s.value.IsValid():
+			return reflect.Value{}, &PatchError{Reason: "contains an absent field " + token}
+		case mode == patchRemove:
+			removed := reflect.New(s.typ).Elem()
+			removed.Set(s.value)
+			s.value.Set(reflect.Zero(s.typ))
+			return removed, nil
+		}
+
+		return p.walk(s.value, nil, mode, value)
+	case reflect.Map:
+		switch {
+		case !s.value.IsValid() && mode != patchAdd:
+			return reflect.Value{}, &PatchError{Reason: "contains an absent key " + token}
+		case mode == patchGet:
+			return s.value, nil
+		case mode == patchRemove:
+			container.SetMapIndex(s.key, reflect.Value{})
+			return s.value, nil
+		}
+
+		var found reflect.Value
+		err := updateEntry(container, s.key, func(elem reflect.Value) (err error) {
+			found, err = p.walk(elem, nil, mode, value)
+			return
+		})
+
+		return found, err
+	default:
+		if mode == patchAdd || mode == patchRemove {
+			return p.resizeSlice(container, token, mode, value)
+		}
+
+		if !s.value.IsValid() {
+			return reflect.Value{}, &PatchError{Reason: "contains an index out of range"}
+		}
+
+		return p.walk(s.value, nil, mode, value)
+	}
+}
+
+// resizeSlice inserts the value made by value into the slice v before the element at token, or
+// removes the element at token.
+func (p *patcher) resizeSlice(v reflect.Value, token string, mode patchMode, value valueFunc) (reflect.Value, error) {
+	if v.Kind() == reflect.Array {
+		return reflect.Value{}, &PatchError{Reason: "resizes an array"}
+	}
+
+	n := v.Len()
+	index, err := strconv.Atoi(token)
+	switch {
+	case token == "-" && mode == patchAdd:
+		index = n
+	case err != nil || index < 0 || index > n || index == n && mode == patchRemove:
+		return reflect.Value{}, &PatchError{Reason: "contains an index out of range"}
+	}
+
+	// Elements are put in a new slice in case the one in v shares its underlying array.
+	if mode == patchRemove {
+		removed := v.Index(index)
+		slice := reflect.MakeSlice(v.Type(), 0, n-1)
+		slice = reflect.AppendSlice(slice, v.Slice(0, index))
+		slice = reflect.AppendSlice(slice, v.Slice(index+1, n))
+		v.Set(slice)
+		return removed, nil
+	}
+
+	put, err := value(v.Type().Elem())
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	slice := reflect.MakeSlice(v.Type(), 0, n+1)
+	slice = reflect.AppendSlice(slice, v.Slice(0, index))
+	slice = reflect.Append(slice, put)
+	slice = reflect.AppendSlice(slice, v.Slice(index, n))
+	v.Set(slice)
+	return put, nil
+}
@@ -0,0 +1,138 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"testing"
+)
+
+type patchedSpec struct {
+	Replicas *int              `json:"replicas,omitempty"`
+	Image    string            `json:"image"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Items    []simpleStruct    `json:"items"`
+}
+
+type patchedObject struct {
+	Name  string       `json:"name"`
+	Spec  *patchedSpec `json:"spec,omitempty"`
+	Cache string       `json:"-"`
+}
+
+type revisedObject struct {
+	Name     string   `json:"name"`
+	Tags     []string `json:"tags"`
+	revision int
+}
+
+func TestDiffPatch(t *testing.T) {
+	three := 3
+	old := &patchedObject{Name: "A", Spec: &patchedSpec{
+		Image:  "v1",
+		Labels: map[string]string{"app": "A", "tier": "web"},
+		Items:  []simpleStruct{{FieldA: "A"}, {FieldA: "B"}, {FieldA: "C"}},
+	}}
+	new := &patchedObject{Name: "B", Spec: &patchedSpec{
+		Replicas: &three,
+		Image:    "v2",
+		Labels:   map[string]string{"app": "B"},
+		Items:    []simpleStruct{{FieldA: "A", FieldB: 1}, {FieldA: "B"}},
+	}}
+	fields := []string{"Name", "Spec.Replicas", "Spec.Image", "Spec.Labels.app", "Spec.Labels.tier", "Spec.Items[*]"}
+
+	patch, err := deepcopy.DiffPatch(old, new, fields...)
+	assert.NilError(t, err)
+	assert.Equal(t, string(patch), `[{"op":"replace","path":"/name","value":"B"},`+
+		`{"op":"replace","path":"/spec/image","value":"v2"},`+
+		`{"op":"replace","path":"/spec/items/0/FieldB","value":1},`+
+		`{"op":"remove","path":"/spec/items/2"},`+
+		`{"op":"replace","path":"/spec/labels/app","value":"B"},`+
+		`{"op":"remove","path":"/spec/labels/tier"},`+
+		`{"op":"add","path":"/spec/replicas","value":3}]`)
+	assert.Equal(t, old.Name, "A")
+	assert.Equal(t, len(old.Spec.Items), 3)
+
+	assert.NilError(t, deepcopy.ApplyPatch(old, patch))
+	assert.DeepEqual(t, old, new)
+
+	patch, err = deepcopy.DiffPatch(&patchedObject{}, new, "Spec.Image", "Spec.Items")
+	assert.NilError(t, err)
+	assert.Equal(t, string(patch), `[{"op":"add","path":"/spec","value":{"image":"v2"}},`+
+		`{"op":"add","path":"/spec/items","value":[{"FieldA":"A","FieldB":1,"FieldC":0},{"FieldA":"B","FieldB":0,"FieldC":0}]}]`)
+
+	patch, err = deepcopy.DiffPatch(&patchedObject{}, &patchedObject{Name: "B", Spec: &patchedSpec{}},
+		"Name", "Spec.Labels", "Spec.Items")
+	assert.NilError(t, err)
+	assert.Equal(t, string(patch), `[{"op":"replace","path":"/name","value":"B"}]`)
+
+	new.Cache = "cached"
+	_, err = deepcopy.DiffPatch(old, new, "Cache")
+	assert.ErrorContains(t, err, "absent from JSON")
+}
+
+func TestDiffPatchWithMergeKeys(t *testing.T) {
+	old := &keyedPod{Containers: []keyedContainer{
+		{Name: "a", Image: "a:v1"}, {Name: "b", Image: "b:v1"}, {Name: "c", Image: "c:v1"},
+	}}
+	new := &keyedPod{Containers: []keyedContainer{{Name: "c", Image: "c:v2"}, {Name: "d", Image: "d:v1"}}}
+
+	patch, err := deepcopy.DiffPatchWith(old, new, []string{"Containers[*].Name", "Containers[*].Image"},
+		deepcopy.WithMergeKeys("Containers", "Name"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(patch), `[{"op":"replace","path":"/Containers/2/Image","value":"c:v2"},`+
+		`{"op":"remove","path":"/Containers/1"},`+
+		`{"op":"remove","path":"/Containers/0"},`+
+		`{"op":"add","path":"/Containers/1","value":{"Name":"d","Image":"d:v1","Ports":null}}]`)
+
+	assert.NilError(t, deepcopy.ApplyPatch(old, patch))
+	assert.DeepEqual(t, old, new)
+}
+
+func TestApplyPatch(t *testing.T) {
+	obj := &patchedObject{Name: "A", Cache: "cached", Spec: &patchedSpec{
+		Labels: map[string]string{"app": "A"},
+		Items:  []simpleStruct{{FieldA: "A"}},
+	}}
+
+	assert.NilError(t, deepcopy.ApplyPatch(obj, []byte(`[
+		{"op":"test","path":"/name","value":"A"},
+		{"op":"add","path":"/spec/items/-","value":{"FieldA":"B"}},
+		{"op":"add","path":"/spec/items/0","value":{"FieldA":"C"}},
+		{"op":"copy","from":"/spec/labels/app","path":"/spec/image"},
+		{"op":"move","from":"/spec/labels/app","path":"/spec/labels/name"},
+		{"op":"remove","path":"/spec/items/1"}
+	]`)))
+	assert.DeepEqual(t, obj, &patchedObject{Name: "A", Cache: "cached", Spec: &patchedSpec{
+		Image:  "A",
+		Labels: map[string]string{"name": "A"},
+		Items:  []simpleStruct{{FieldA: "C"}, {FieldA: "B"}},
+	}})
+
+	items := obj.Spec.Items
+	err := deepcopy.ApplyPatch(obj, []byte(`[
+		{"op":"replace","path":"/spec/items/0/FieldB","value":1},
+		{"op":"test","path":"/name","value":"B"}
+	]`))
+	assert.ErrorContains(t, err, `patch operation "test" at "/name" fails the test`)
+	assert.Equal(t, obj.Spec.Items[0].FieldB, 0)
+	assert.Equal(t, &obj.Spec.Items[0], &items[0])
+
+	err = deepcopy.ApplyPatch(obj, []byte(`[{"op":"replace","path":"/spec/labels/app","value":"A"}]`))
+	assert.ErrorContains(t, err, "absent key app")
+	err = deepcopy.ApplyPatch(obj, []byte(`[{"op":"remove","path":"/spec/items/2"}]`))
+	assert.ErrorContains(t, err, "out of range")
+	err = deepcopy.ApplyPatch(obj, []byte(`[{"op":"add","path":"/status","value":{}}]`))
+	assert.ErrorContains(t, err, "unknown field status")
+	assert.Equal(t, deepcopy.ApplyPatch(nil, []byte(`[]`)), deepcopy.ErrNilDestination)
+
+	tags := []string{"a"}
+	revised := &revisedObject{Name: "A", Tags: tags, revision: 1}
+	assert.NilError(t, deepcopy.ApplyPatch(revised, []byte(`[
+		{"op":"replace","path":"/name","value":"B"},
+		{"op":"replace","path":"/tags/0","value":"b"}
+	]`)))
+	assert.Equal(t, revised.Name, "B")
+	assert.Equal(t, revised.revision, 1)
+	assert.DeepEqual(t, revised.Tags, []string{"b"})
+	assert.DeepEqual(t, tags, []string{"a"})
+}
@@ -0,0 +1,142 @@
+package deepcopy
+
+import (
+	"strconv"
+	"strings"
+)
+
+// segmentKind is the kind of a segment of a field path, as well as the kind of the tree node
+// the segment is parsed into.
+type segmentKind int
+
+const (
+	// segmentField selects a field of a structure, e.g. "Spec".
+	segmentField segmentKind = iota
+	// segmentIndex selects an element of a slice, e.g. "[0]", or "[-1]" counted from the end.
+	segmentIndex
+	// segmentWildcard selects all elements of a slice, i.e. "[*]".
+	segmentWildcard
+	// segmentKey selects an entry of a map by a quoted key, e.g. `["config.yaml"]`. Field
+	// segments and index segments applied to maps also select entries, e.g. "Labels.app".
+	segmentKey
+)
+
+type segment struct {
+	kind  segmentKind
+	name  string
+	index int
+}
+
+// key is the canonical form of the segment, which is also the key of the branch in a tree.
+func (s segment) key() string {
+	switch s.kind {
+	case segmentIndex:
+		return "[" + strconv.Itoa(s.index) + "]"
+	case segmentWildcard:
+		return "[*]"
+	case segmentKey:
+		return "[" + strconv.Quote(s.name) + "]"
+	default:
+		return s.name
+	}
+}
+
+// parsePath splits a field path like `Spec.Containers[0].Ports[*].Name` or
+// `Data["config.yaml"]` into segments.
+func parsePath(path string) (segments []segment, err error) {
+	pos := 0
+	for {
+		if strings.HasPrefix(path[pos:], `["`) {
+			end := quotedKeyEnd(path[pos+1:])
+			if end < 0 || !strings.HasPrefix(path[pos+1+end:], "]") {
+				err = &PathError{Path: path, Index: len(segments), Reason: "contains an unclosed quoted key"}
+				return
+			}
+
+			key, unquoteErr := strconv.Unquote(path[pos+1 : pos+1+end])
+			if unquoteErr != nil {
+				err = &PathError{Path: path, Index: len(segments), Reason: "contains an invalid quoted key"}
+				return
+			}
+
+			segments = append(segments, segment{kind: segmentKey, name: key})
+			pos += end + 2
+		} else if pos < len(path) && path[pos] == '[' {
+			end := strings.IndexByte(path[pos:], ']')
+			if end < 0 {
+				err = &PathError{Path: path, Index: len(segments), Reason: "contains an unclosed bracket"}
+				return
+			}
+
+			selector := path[pos+1 : pos+end]
+			pos += end + 1
+			if selector == "*" {
+				segments = append(segments, segment{kind: segmentWildcard})
+			} else if index, convErr := strconv.Atoi(selector); convErr == nil {
+				segments = append(segments, segment{kind: segmentIndex, index: index})
+			} else {
+				err = &PathError{Path: path, Index: len(segments), Reason: "contains an invalid index " + selector}
+				return
+			}
+		} else {
+			end := strings.IndexAny(path[pos:], ".[")
+			if end < 0 {
+				end = len(path) - pos
+			}
+
+			if end == 0 {
+				err = &PathError{Path: path, Index: len(segments), Reason: "contains a blank segment"}
+				return
+			}
+
+			segments = append(segments, segment{kind: segmentField, name: path[pos : pos+end]})
+			pos += end
+		}
+
+		if pos == len(path) {
+			return
+		}
+
+		switch path[pos] {
+		case '.':
+			pos++
+			if pos < len(path) && path[pos] == '[' {
+				err = &PathError{Path: path, Index: len(segments), Reason: "contains a blank segment"}
+				return
+			}
+		case '[':
+		default:
+			err = &PathError{Path: path, Index: len(segments), Reason: "misses a dot after a bracket"}
+			return
+		}
+	}
+}
+
+// quotedKeyEnd returns the position right after the closing quote of the quoted string
+// leading s, or -1 if the quote isn't closed.
+func quotedKeyEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// joinKeys is the reverse of parsePath, which joins keys of segments into a field path.
+func joinKeys(keys []string) string {
+	var b strings.Builder
+	for i, key := range keys {
+		if i > 0 && !strings.HasPrefix(key, "[") {
+			b.WriteByte('.')
+		}
+
+		b.WriteString(key)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,183 @@
+package deepcopy
+
+import (
+	"reflect"
+	"sync"
+)
+
+var interfaceType = reflect.TypeOf((*Interface)(nil)).Elem()
+
+// copyMode tells how a field is copied. It is set via the struct tag `deepcopy:"..."`.
+type copyMode int
+
+const (
+	// copyDeep is the default mode which deeply copies the field.
+	copyDeep copyMode = iota
+	// copySkip, set via `deepcopy:"-"`, never copies the field.
+	copySkip
+	// copyShallow, set via `deepcopy:"shallow"`, assigns the field as-is, so that pointers,
+	// slices and maps are shared between the source and the copy.
+	copyShallow
+	// copyZero, set via `deepcopy:"zero"`, resets the field to its zero value in the copy.
+	copyZero
+)
+
+const tagName = "deepcopy"
+
+func parseCopyMode(tag reflect.StructTag) copyMode {
+	switch tag.Get(tagName) {
+	case "-":
+		return copySkip
+	case "shallow":
+		return copyShallow
+	case "zero":
+		return copyZero
+	default:
+		return copyDeep
+	}
+}
+
+// fieldPlan is a field of a structure.
+type fieldPlan struct {
+	index []int
+	mode  copyMode
+	// unexported is true if the field can only be accessed via unsafe.
+	unexported bool
+}
+
+// typePlan is what copying needs to know about a type. Plans are compiled once per type
+// and cached in plans, so reflection on types isn't repeated in every copy.
+type typePlan struct {
+	kind reflect.Kind
+	// copier is true if the type implements Interface and isn't an interface itself.
+	copier bool
+	// fields are exported fields of a structure.
+	fields []fieldPlan
+	// allFields are all fields of a structure including unexported ones.
+	allFields []fieldPlan
+	// byName caches fields found by name, including promoted ones. It is keyed by fieldKey
+	// and valued by *fieldPlan, which is nil if no exported field found.
+	byName sync.Map
+}
+
+var plans sync.Map
+
+// planOf returns the compiled plan of typ.
+func planOf(typ reflect.Type) *typePlan {
+	if p, found := plans.Load(typ); found {
+		return p.(*typePlan)
+	}
+
+	p, _ := plans.LoadOrStore(typ, compilePlan(typ))
+	return p.(*typePlan)
+}
+
+func compilePlan(typ reflect.Type) *typePlan {
+	p := &typePlan{
+		kind:   typ.Kind(),
+		copier: typ.Kind() != reflect.Interface && typ.Implements(interfaceType),
+	}
+
+	if p.kind == reflect.Struct {
+		for i := 0; i < typ.NumField(); i++ {
+			// The Type's StructField for a given field is checked to see if StructField.PkgPath
+			// is set to determine if the field is exported or not because CanSet() returns false
+			// for settable fields.  I'm not sure why.  -mohae
+			field := typ.Field(i)
+			f := fieldPlan{index: field.Index, mode: parseCopyMode(field.Tag), unexported: field.PkgPath != ""}
+			if !f.unexported {
+				p.fields = append(p.fields, f)
+			}
+
+			p.allFields = append(p.allFields, f)
+		}
+	}
+
+	return p
+}
+
+// fieldKey is the key of typePlan.byName.
+type fieldKey struct {
+	resolver NameResolver
+	name     string
+}
+
+// field returns the exported field of the structure typ named name by resolver, or nil if not
+// found. Go field names are used if resolver is nil.
+func (p *typePlan) field(typ reflect.Type, name string, resolver NameResolver) *fieldPlan {
+	if r, ok := resolver.(*cachingResolver); ok {
+		return r.field(typ, name)
+	}
+
+	if resolver != nil && !reflect.TypeOf(resolver).Comparable() {
+		// Resolvers not made via WithNameResolver can't be keys of the cache, so fields are
+		// resolved every time.
+		return resolveField(typ, name, resolver)
+	}
+
+	key := fieldKey{resolver, name}
+	if f, found := p.byName.Load(key); found {
+		return f.(*fieldPlan)
+	}
+
+	var f *fieldPlan
+	if resolver != nil {
+		f = resolveField(typ, name, resolver)
+	} else if field, found := typ.FieldByName(name); found && field.PkgPath == "" {
+		f = &fieldPlan{index: field.Index, mode: parseCopyMode(field.Tag)}
+	}
+
+	p.byName.Store(key, f)
+	return f
+}
+
+// fieldByName is like reflect.Value.FieldByName on a structure but uses the cached plan
+// and ignores unexported fields. It returns the zero Value if no field found, as well as
+// how the field should be copied. Fields promoted from nil embedded pointers are read as new zero
+// values, which are not part of v.
+func fieldByName(v reflect.Value, name string, resolver NameResolver) (reflect.Value, copyMode) {
+	field, mode, _ := lookupField(v, name, resolver, false)
+	return field, mode
+}
+
+// settableField is like fieldByName but allocates nil embedded pointers the field is promoted
+// from, so that the field can be set.
+func settableField(v reflect.Value, name string, resolver NameResolver) reflect.Value {
+	field, _, _ := lookupField(v, name, resolver, true)
+	return field
+}
+
+// lookupField is like fieldByName but allocates nil embedded pointers the field is promoted from
+// if alloc is true. Otherwise, detached is true if any of them is nil, which means the field is a
+// new zero value.
+func lookupField(v reflect.Value, name string, resolver NameResolver, alloc bool) (
+	field reflect.Value, mode copyMode, detached bool) {
+	f := planOf(v.Type()).field(v.Type(), name, resolver)
+	if f == nil {
+		return reflect.Value{}, copyDeep, false
+	}
+
+	field = v
+	for n, i := range f.index {
+		if n > 0 && field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				if !alloc {
+					return reflect.New(field.Type().Elem().FieldByIndex(f.index[n:]).Type).Elem(), f.mode, true
+				}
+
+				if !field.CanSet() {
+					// Embedded pointers of unexported types can only be set via unsafe.
+					field = exposeField(field)
+				}
+
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+
+			field = field.Elem()
+		}
+
+		field = field.Field(i)
+	}
+
+	return field, f.mode, false
+}
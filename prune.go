@@ -0,0 +1,124 @@
+package deepcopy
+
+import "reflect"
+
+// copyPruned deeply copies src into dst except the parts excluded by hierarchy, which are
+// left as they were in dst.
+func copyPruned(src, dst reflect.Value, hierarchy *tree, o *options) error {
+	if !hierarchy.hasExclusions() {
+		return copyRecursive(src, dst, o)
+	}
+
+	// copyRecursive never writes through pointers, maps or slices it replaces, so a shallow copy
+	// of dst keeps all the parts to be restored.
+	old := reflect.New(dst.Type()).Elem()
+	old.Set(dst)
+	if err := copyRecursive(src, dst, o); err != nil {
+		return err
+	}
+
+	restoreExcluded(dst, old, hierarchy, o)
+	return nil
+}
+
+// restoreExcluded puts parts of old excluded by hierarchy back into dst. Excluded parts which
+// are not in old are reset to their zero values.
+func restoreExcluded(dst, old reflect.Value, hierarchy *tree, o *options) {
+	for dst.Kind() == reflect.Ptr || dst.Kind() == reflect.Interface {
+		if dst.IsNil() {
+			return
+		}
+
+		dst = dst.Elem()
+		if old.IsValid() && !old.IsNil() {
+			old = old.Elem()
+		} else {
+			old = reflect.Value{}
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Slice:
+		for j := 0; j < dst.Len(); j++ {
+			sub, found := hierarchy.elementTree(j, dst.Len())
+			if !found {
+				continue
+			}
+
+			var oldElem reflect.Value
+			if old.IsValid() && j < old.Len() {
+				oldElem = old.Index(j)
+			}
+
+			restoreValue(dst.Index(j), oldElem, &sub, o)
+		}
+	case reflect.Map:
+		for _, branch := range hierarchy.branches {
+			key, ok := branch.mapKey(dst.Type())
+			if !ok {
+				continue
+			}
+
+			var oldElem reflect.Value
+			if old.IsValid() && !old.IsNil() {
+				oldElem = old.MapIndex(key)
+			}
+
+			if branch.excluded {
+				dst.SetMapIndex(key, oldElem)
+				continue
+			}
+
+			if dst.IsNil() || !dst.MapIndex(key).IsValid() || !branch.hasExclusions() {
+				continue
+			}
+
+			// Entries of maps are not addressable, so the copy of the entry is restored then put back.
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			elem.Set(dst.MapIndex(key))
+			restoreExcluded(elem, oldElem, &branch, o)
+			dst.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		for value, branch := range hierarchy.branches {
+			field, _, detached := lookupField(dst, value, o.resolver, false)
+			if !field.IsValid() {
+				continue
+			}
+
+			var oldField reflect.Value
+			if old.IsValid() {
+				oldField, _ = fieldByName(old, value, o.resolver)
+			}
+
+			if detached {
+				// Nothing is restored into fields promoted from nil embedded pointers unless the
+				// old ones aren't zero.
+				if !oldField.IsValid() || isZero(oldField) {
+					continue
+				}
+
+				field = settableField(dst, value, o.resolver)
+			}
+
+			restoreValue(field, oldField, &branch, o)
+		}
+	}
+}
+
+// restoreValue puts old back into dst if hierarchy is excluded, or restores its excluded parts.
+func restoreValue(dst, old reflect.Value, hierarchy *tree, o *options) {
+	if hierarchy.excluded {
+		if old.IsValid() {
+			dst.Set(old)
+		} else {
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+
+		return
+	}
+
+	if hierarchy.hasExclusions() {
+		restoreExcluded(dst, old, hierarchy, o)
+	}
+}
@@ -0,0 +1,105 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"strings"
+	"testing"
+)
+
+type exclusionSpec struct {
+	NodeName   string
+	Containers []simpleStruct
+	Labels     map[string]string
+}
+
+type exclusionObject struct {
+	Name   string
+	Spec   exclusionSpec
+	Status string
+}
+
+func newExclusionObject() exclusionObject {
+	return exclusionObject{
+		Name: "A",
+		Spec: exclusionSpec{
+			NodeName:   "node",
+			Containers: []simpleStruct{{FieldA: "A", FieldB: 1}, {FieldA: "B", FieldB: 2}},
+			Labels:     map[string]string{"app": "A", "version": "1"},
+		},
+		Status: "Running",
+	}
+}
+
+func TestExcept(t *testing.T) {
+	src := newExclusionObject()
+	dst := exclusionObject{Status: "Pending", Spec: exclusionSpec{NodeName: "other"}}
+	assert.Assert(t, deepcopy.Except(&dst, &src, "Status", "Spec.NodeName", "Spec.Labels.version"))
+	assert.Equal(t, dst.Name, src.Name)
+	assert.Equal(t, dst.Status, "Pending")
+	assert.Equal(t, dst.Spec.NodeName, "other")
+	assert.DeepEqual(t, dst.Spec.Containers, src.Spec.Containers)
+	assert.DeepEqual(t, dst.Spec.Labels, map[string]string{"app": "A"})
+
+	dst.Spec.Containers[0].FieldA = "C"
+	assert.Equal(t, src.Spec.Containers[0].FieldA, "A")
+
+	_, err := deepcopy.ExceptE(&dst, &src, "Spec.")
+	assert.ErrorContains(t, err, "blank segment")
+}
+
+func TestMixedExclusionPaths(t *testing.T) {
+	src := newExclusionObject()
+
+	var dst exclusionObject
+	assert.Assert(t, deepcopy.Partial(&dst, &src, "Spec", "!Spec.NodeName", "!Spec.Containers[*].FieldB"))
+	assert.Equal(t, dst.Name, "")
+	assert.Equal(t, dst.Spec.NodeName, "")
+	assert.DeepEqual(t, dst.Spec.Labels, src.Spec.Labels)
+	assert.DeepEqual(t, dst.Spec.Containers, []simpleStruct{{FieldA: "A"}, {FieldA: "B"}})
+
+	dst = exclusionObject{}
+	assert.Assert(t, deepcopy.Partial(&dst, &src, "!Status"))
+	assert.Equal(t, dst.Name, src.Name)
+	assert.Equal(t, dst.Status, "")
+
+	dst = exclusionObject{Spec: exclusionSpec{NodeName: "other"}}
+	assert.Assert(t, deepcopy.OnChange(&dst, &src, "Spec", "!Spec.NodeName"))
+	assert.Equal(t, dst.Spec.NodeName, "other")
+	assert.DeepEqual(t, dst.Spec.Containers, src.Spec.Containers)
+	assert.Assert(t, !deepcopy.OnChange(&dst, &src, "Spec", "!Spec.NodeName"))
+
+	assert.Assert(t, deepcopy.OnChange(&dst, &src, "!Spec.NodeName", "!Status"))
+	assert.Equal(t, dst.Name, src.Name)
+	assert.Equal(t, dst.Status, "")
+	assert.Assert(t, !deepcopy.OnChange(&dst, &src, "!Spec.NodeName", "!Status"))
+
+	replicator := deepcopy.NewExceptReplicator("Spec")
+	dst = exclusionObject{}
+	assert.Assert(t, replicator.Copy(&dst, &src))
+	assert.Equal(t, dst.Status, src.Status)
+	assert.Assert(t, dst.Spec.Containers == nil)
+}
+
+func TestExceptReplicatorWithOptions(t *testing.T) {
+	src := newExclusionObject()
+	dst := exclusionObject{Status: "Pending", Spec: exclusionSpec{
+		Containers: []simpleStruct{{FieldA: "B"}, {FieldA: "stale"}, {FieldA: "A"}},
+	}}
+
+	replicator, err := deepcopy.NewExceptReplicatorWith([]string{"status"},
+		deepcopy.WithNameResolver(lowerNames(strings.ToLower)), deepcopy.WithMergeKeys("spec.containers", "fielda"))
+	assert.NilError(t, err)
+	assert.Assert(t, replicator.Copy(&dst, &src))
+	assert.Equal(t, dst.Status, "Pending")
+	assert.Equal(t, dst.Name, src.Name)
+	assert.DeepEqual(t, dst.Spec.Containers, []simpleStruct{{FieldA: "B", FieldB: 2}, {FieldA: "A", FieldB: 1}})
+	assert.Assert(t, !replicator.Copy(&dst, &src))
+
+	unexported := struct{ hidden []int }{hidden: []int{1}}
+	var copied struct{ hidden []int }
+	replicator, err = deepcopy.NewExceptReplicatorWith(nil, deepcopy.IncludeUnexported())
+	assert.NilError(t, err)
+	assert.Assert(t, replicator.Copy(&copied, &unexported))
+	assert.DeepEqual(t, copied.hidden, []int{1})
+}
@@ -4,32 +4,207 @@ import "reflect"
 
 type PartialReplicator interface {
 	Copy(dst, src interface{}) (copied bool)
+	// CopyE is like Copy but returns an error instead of panicking.
+	CopyE(dst, src interface{}) (copied bool, err error)
 }
 
 func NewPartialReplicator(fieldsSelected ...string) PartialReplicator {
+	r, err := NewPartialReplicatorE(fieldsSelected...)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// NewPartialReplicatorE is like NewPartialReplicator but returns an error instead of panicking
+// if any of the fields is malformed.
+func NewPartialReplicatorE(fieldsSelected ...string) (PartialReplicator, error) {
+	return NewPartialReplicatorWith(fieldsSelected)
+}
+
+// NewPartialReplicatorWith is like NewPartialReplicatorE but configured by opts.
+func NewPartialReplicatorWith(fieldsSelected []string, opts ...Option) (PartialReplicator, error) {
+	hierarchy, err := fieldsToTree(fieldsSelected)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	if err = applyMergeKeys(&hierarchy, o); err != nil {
+		return nil, err
+	}
+
+	return &partialReplicator{
+		hierarchy: hierarchy,
+		opts:      o,
+	}, nil
+}
+
+// NewExceptReplicator creates a replicator which deeply copies the whole source except the
+// fields excluded. Unlike other replicators, it copies into the destination in place, so the
+// fields excluded are left as they are in the destination.
+func NewExceptReplicator(fieldsExcluded ...string) PartialReplicator {
+	r, err := NewExceptReplicatorE(fieldsExcluded...)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// NewExceptReplicatorE is like NewExceptReplicator but returns an error instead of panicking
+// if any of the fields is malformed.
+func NewExceptReplicatorE(fieldsExcluded ...string) (PartialReplicator, error) {
+	return NewExceptReplicatorWith(fieldsExcluded)
+}
+
+// NewExceptReplicatorWith is like NewExceptReplicatorE but configured by opts. Elements of slices
+// with merge keys are matched as OnChange does, so those absent from the source are removed.
+func NewExceptReplicatorWith(fieldsExcluded []string, opts ...Option) (PartialReplicator, error) {
+	fields := make([]string, 0, len(fieldsExcluded))
+	for _, field := range fieldsExcluded {
+		fields = append(fields, "!"+field)
+	}
+
+	hierarchy, err := fieldsToTree(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy.selected = true
+	o := newOptions(opts)
+	if err = applyMergeKeys(&hierarchy, o); err != nil {
+		return nil, err
+	}
+
 	return &partialReplicator{
-		hierarchy: fieldsToTree(fieldsSelected),
+		hierarchy: hierarchy,
+		opts:      o,
+		inPlace:   true,
+	}, nil
+}
+
+// NewTypedPartialReplicator is like NewPartialReplicatorE but also resolves all the fields against
+// the type of prototype. Every field unknown to the type is reported in a PathErrors. The
+// replicator created only accepts objects of the same type as prototype, or pointers to it.
+func NewTypedPartialReplicator(prototype interface{}, fieldsSelected ...string) (PartialReplicator, error) {
+	typ := reflect.TypeOf(prototype)
+	if typ == nil {
+		return nil, &KindError{Kind: reflect.Invalid}
 	}
+
+	return newTypedPartialReplicator(typ, fieldsSelected, newOptions(nil))
+}
+
+// newTypedPartialReplicator creates a replicator which only accepts pointers to objects of typ,
+// or objects of typ if it is a pointer type, with fields resolved against typ.
+func newTypedPartialReplicator(typ reflect.Type, fieldsSelected []string, o *options) (*partialReplicator, error) {
+	hierarchy, err := fieldsToTree(fieldsSelected)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateMergeKeys(typ, o); err != nil {
+		return nil, err
+	}
+
+	if err = applyMergeKeys(&hierarchy, o); err != nil {
+		return nil, err
+	}
+
+	if errs := validateTree(typ, &hierarchy, nil, o); len(errs) > 0 {
+		return nil, errs
+	}
+
+	if typ.Kind() != reflect.Ptr {
+		typ = reflect.PtrTo(typ)
+	}
+
+	return &partialReplicator{
+		hierarchy: hierarchy,
+		opts:      o,
+		typ:       typ,
+	}, nil
 }
 
 type partialReplicator struct {
 	hierarchy tree
+	opts      *options
+	// typ is the type of objects the replicator accepts. It is nil if the replicator is untyped.
+	typ reflect.Type
+	// inPlace is true if the source is copied into the destination directly rather than a mimic.
+	inPlace bool
 }
 
 func (r partialReplicator) Copy(dst, src interface{}) (copied bool) {
+	copied, err := r.CopyE(dst, src)
+	if err != nil {
+		panic(err)
+	}
+
+	return
+}
+
+func (r partialReplicator) CopyE(dst, src interface{}) (copied bool, err error) {
 	if src == nil {
 		return
 	}
 
-	if dst == nil {
-		panic("the destination must not be nil")
+	if err = checkObjects(dst, src); err != nil {
+		return
 	}
 
-	if reflect.TypeOf(src) != reflect.TypeOf(dst) {
-		panic("both src and dst must have the same type")
+	if r.typ != nil && reflect.TypeOf(src) != r.typ {
+		err = &TypeMismatchError{Src: reflect.TypeOf(src), Dst: r.typ}
+		return
+	}
+
+	inPlace := r.inPlace || r.opts.merge
+	if r.hierarchy.selected && inPlace && r.hierarchy.hasKeyedBranches() {
+		if r.opts.merge {
+			return mergeValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem(), &r.hierarchy, copyDeep,
+				r.opts)
+		}
+
+		// Elements of slices in the destination are matched by merge keys as OnChange does. Options
+		// are copied since OnChange sets them up for every call.
+		o := *r.opts
+		var rec *changeRecorder
+		if rec, err = recordTreeChanges(dst, src, r.hierarchy, &o); err != nil {
+			return
+		}
+
+		return len(rec.changes) > 0, nil
+	}
+
+	if r.hierarchy.selected {
+		srcV, target := reflect.ValueOf(src).Elem(), reflect.ValueOf(dst).Elem()
+		if !inPlace {
+			target = reflect.New(srcV.Type()).Elem()
+		}
+
+		if err = copyPruned(srcV, target, &r.hierarchy, r.opts); err != nil {
+			return
+		}
+
+		copied = !isZero(srcV)
+		if copied && !inPlace {
+			reflect.ValueOf(dst).Elem().Set(target)
+		}
+
+		return
+	}
+
+	if inPlace {
+		return mergeObject(reflect.ValueOf(dst), reflect.ValueOf(src), &r.hierarchy, r.opts)
+	}
+
+	mimic, copied, err := inspectObject(reflect.ValueOf(src), &r.hierarchy, r.opts)
+	if err != nil {
+		return
 	}
 
-	mimic, copied := inspectObject(reflect.ValueOf(src), &r.hierarchy)
 	if copied {
 		reflect.ValueOf(dst).Elem().Set(mimic.Elem())
 	}
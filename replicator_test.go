@@ -0,0 +1,90 @@
+package deepcopy_test
+
+import (
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"testing"
+)
+
+func TestTypedPartialReplicator(t *testing.T) {
+	src := structWithSliceOfPointers{
+		IntA: 101,
+		SliceA: []*simpleStruct{
+			{FieldA: "SliceA", FieldB: 102},
+		},
+	}
+
+	replicator, err := deepcopy.NewTypedPartialReplicator(structWithSliceOfPointers{}, "SliceA.FieldA", "IntA")
+	assert.NilError(t, err)
+
+	var dst structWithSliceOfPointers
+	copied, err := replicator.CopyE(&dst, &src)
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.Equal(t, dst.IntA, src.IntA)
+	assert.Equal(t, dst.SliceA[0].FieldA, src.SliceA[0].FieldA)
+	assert.Equal(t, dst.SliceA[0].FieldB, 0)
+
+	var other simpleStruct
+	_, err = replicator.CopyE(&other, &simpleStruct{})
+	_, ok := err.(*deepcopy.TypeMismatchError)
+	assert.Assert(t, ok, "%#v", err)
+}
+
+func TestTypedPartialReplicatorWithUnknownFields(t *testing.T) {
+	_, err := deepcopy.NewTypedPartialReplicator(&structWithSliceOfPointers{},
+		"SliceA.FieldX",
+		"SliceA.FieldA",
+		"IntA.Value",
+		"Missing",
+		"Replicas")
+
+	errs, ok := err.(deepcopy.PathErrors)
+	assert.Assert(t, ok, "%#v", err)
+	assert.Equal(t, len(errs), 3, err.Error())
+	assert.Equal(t, errs[0].Path, "IntA.Value")
+	assert.Equal(t, errs[0].Index, 1)
+	assert.Equal(t, errs[1].Path, "Missing")
+	assert.Equal(t, errs[1].Index, 0)
+	assert.Equal(t, errs[2].Path, "SliceA.FieldX")
+	assert.Equal(t, errs[2].Index, 1)
+}
+
+func TestMergingReplicator(t *testing.T) {
+	src := structWithSliceOfPointers{
+		IntA: 101,
+		SliceA: []*simpleStruct{
+			{FieldA: "A", FieldB: 1},
+			{FieldA: "B", FieldB: 2},
+		},
+	}
+
+	replicator, err := deepcopy.NewPartialReplicatorWith([]string{"SliceA.FieldA"}, deepcopy.WithMerge())
+	assert.NilError(t, err)
+
+	dst := structWithSliceOfPointers{
+		IntA:   1,
+		SliceA: []*simpleStruct{{FieldA: "C", FieldB: 3}},
+	}
+	assert.Assert(t, replicator.Copy(&dst, &src))
+	assert.Equal(t, dst.IntA, 1)
+	assert.Equal(t, len(dst.SliceA), 2)
+	assert.DeepEqual(t, *dst.SliceA[0], simpleStruct{FieldA: "A", FieldB: 3})
+	assert.DeepEqual(t, *dst.SliceA[1], simpleStruct{FieldA: "B"})
+
+	maps := structWithMaps{
+		Labels: map[string]string{"app": "A"},
+		Items:  map[string]simpleStruct{"A": {FieldA: "A", FieldB: 1}},
+	}
+	mergedMaps := structWithMaps{
+		Labels: map[string]string{"version": "1"},
+		Items:  map[string]simpleStruct{"A": {FieldB: 2}},
+	}
+	copied, err := deepcopy.PartialWith(&mergedMaps, &maps, []string{"Labels.app", "Items.A.FieldA", "Data"},
+		deepcopy.WithMerge())
+	assert.NilError(t, err)
+	assert.Assert(t, copied)
+	assert.DeepEqual(t, mergedMaps.Labels, map[string]string{"app": "A", "version": "1"})
+	assert.DeepEqual(t, mergedMaps.Items, map[string]simpleStruct{"A": {FieldA: "A", FieldB: 2}})
+	assert.Assert(t, mergedMaps.Data == nil)
+}
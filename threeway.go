@@ -0,0 +1,415 @@
+package deepcopy
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Conflict is a value changed since base in both local and remote but differently.
+type Conflict struct {
+	// Path is the field path of the value, like paths of changes made by OnChange.
+	Path string
+	// Base, Local and Remote are values at Path in the objects, which are nil if absent.
+	Base   interface{}
+	Local  interface{}
+	Remote interface{}
+}
+
+// ConflictResolver returns the value merged at the path of conflict, which must be assignable to
+// the value there. nil removes the value if it is an entry of a map, or zeroes it otherwise.
+type ConflictResolver func(conflict Conflict) (interface{}, error)
+
+// PreferLocal resolves conflicts by keeping local values. It is the default resolver.
+func PreferLocal(conflict Conflict) (interface{}, error) {
+	return conflict.Local, nil
+}
+
+// PreferRemote resolves conflicts by taking remote values.
+func PreferRemote(conflict Conflict) (interface{}, error) {
+	return conflict.Remote, nil
+}
+
+// WithConflictResolver makes ThreeWayMerge resolve conflicts via resolver.
+func WithConflictResolver(resolver ConflictResolver) Option {
+	return func(o *options) {
+		o.conflictResolver = resolver
+	}
+}
+
+// ThreeWayMerge merges changes made to fields selected of base in remote into a copy of local,
+// except those conflicting with changes made in local, which are resolved via PreferLocal. All
+// three must be pointers of the same type. Changes are what OnChange would make to base, and
+// those in the same path, or one in the path of the other, conflict unless they are the same.
+// Like changes reported by OnChange, those in fields selected as a whole are merged at paths of
+// the values changed in them, while slices resized are changed as a whole. Elements of slices with
+// merge keys are changed where local has them, even if local reordered them.
+func ThreeWayMerge(base, local, remote interface{}, fieldsSelected ...string) (
+	merged interface{}, conflicts []Conflict, err error) {
+	return ThreeWayMergeWith(base, local, remote, fieldsSelected)
+}
+
+// ThreeWayMergeWith is like ThreeWayMerge but configured by opts.
+func ThreeWayMergeWith(base, local, remote interface{}, fieldsSelected []string, opts ...Option) (
+	merged interface{}, conflicts []Conflict, err error) {
+	o := newOptions(opts)
+	if err = checkObjects(local, base); err != nil {
+		return
+	}
+
+	if err = checkObjects(local, remote); err != nil {
+		return
+	}
+
+	localChanges, err := diffUnits(base, local, fieldsSelected, o)
+	if err != nil {
+		return
+	}
+
+	remoteChanges, err := diffUnits(base, remote, fieldsSelected, o)
+	if err != nil {
+		return
+	}
+
+	hierarchy, err := fieldsToTree(fieldsSelected)
+	if err != nil {
+		return
+	}
+
+	if err = applyMergeKeys(&hierarchy, o); err != nil {
+		return
+	}
+
+	if merged, err = CopyWithOptions(local, WithCopier(o.copier), WithUncopyablePolicy(o.uncopyable)); err != nil {
+		return
+	}
+
+	m := &threeWay{
+		o:         o,
+		hierarchy: hierarchy,
+		base:      reflect.ValueOf(base).Elem(),
+		local:     reflect.ValueOf(local).Elem(),
+		remote:    reflect.ValueOf(remote).Elem(),
+		merged:    reflect.ValueOf(merged).Elem(),
+		handled:   make(map[string]bool),
+	}
+
+	for _, change := range remoteChanges {
+		if err = m.mergeChange(change, localChanges); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sort.SliceStable(m.conflicts, func(i, j int) bool {
+		return m.conflicts[i].Path < m.conflicts[j].Path
+	})
+
+	return merged, m.conflicts, nil
+}
+
+// unit is a change in a three-way merge.
+type unit struct {
+	keys []string
+	// value is the value after the change, which is invalid if the value is removed.
+	value reflect.Value
+}
+
+// diffUnits returns changes OnChange would make to base with fields selected of changed. Changes
+// resizing slices are merged into changes of the slices as a whole.
+func diffUnits(base, changed interface{}, fieldsSelected []string, o *options) ([]unit, error) {
+	o.dryRun = true
+	rec, err := recordChanges(base, changed, fieldsSelected, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []unit
+	resized := make(map[string]bool)
+	for i, step := range rec.steps {
+		if step.resize == resizeNone {
+			units = append(units, unit{keys: step.keys, value: reflect.ValueOf(rec.changes[i].New)})
+			continue
+		}
+
+		keys := step.keys[:len(step.keys)-1]
+		if path := joinKeys(keys); !resized[path] {
+			resized[path] = true
+			units = append(units, unit{keys: keys, value: valueAt(rec.result.Elem(), keys, o.resolver)})
+		}
+	}
+
+	return units, nil
+}
+
+// threeWay merges changes in remote into merged, which is a copy of local. Paths of changes are
+// paths in base, where elements of keyed slices may be in another order than in local.
+type threeWay struct {
+	o                           *options
+	hierarchy                   tree
+	base, local, remote, merged reflect.Value
+	conflicts                   []Conflict
+	// handled are paths of conflicts handled.
+	handled map[string]bool
+}
+
+func (m *threeWay) mergeChange(change unit, localChanges []unit) error {
+	conflictKeys := change.keys
+	var overlapped []unit
+	for _, local := range localChanges {
+		if !isPrefixOf(local.keys, change.keys) && !isPrefixOf(change.keys, local.keys) {
+			continue
+		}
+
+		overlapped = append(overlapped, local)
+		if len(local.keys) < len(conflictKeys) {
+			conflictKeys = local.keys
+		}
+	}
+
+	switch {
+	case len(overlapped) == 0:
+		keys, found, err := m.keysIn(m.merged, change.keys)
+		switch {
+		case err != nil:
+			return err
+		case found:
+			return m.set(keys, change.value)
+		}
+
+		// The element changed is absent from local, which conflicts with the change.
+	case len(overlapped) == 1 && len(overlapped[0].keys) == len(change.keys) && m.same(overlapped[0].value, change.value):
+		return nil
+	}
+
+	path := joinKeys(conflictKeys)
+	if m.handled[path] {
+		return nil
+	}
+
+	m.handled[path] = true
+	conflict := Conflict{
+		Path:   path,
+		Base:   interfaceOf(valueAt(m.base, conflictKeys, m.o.resolver)),
+		Local:  interfaceOf(m.valueIn(m.local, conflictKeys)),
+		Remote: interfaceOf(valueAt(m.remote, conflictKeys, m.o.resolver)),
+	}
+
+	m.conflicts = append(m.conflicts, conflict)
+	resolver := m.o.conflictResolver
+	if resolver == nil {
+		resolver = PreferLocal
+	}
+
+	resolved, err := resolver(conflict)
+	if err != nil {
+		return err
+	}
+
+	keys, found, err := m.keysIn(m.merged, conflictKeys)
+	if err != nil || !found {
+		// The element the value is in is absent from local, so there is nowhere to put it.
+		return err
+	}
+
+	return m.set(keys, reflect.ValueOf(resolved))
+}
+
+// same tells whether values after two changes are the same.
+func (m *threeWay) same(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+
+	return a.Type() == b.Type() && newEqualState(m.o.equality).equal(a, b, nil)
+}
+
+// valueIn returns the value in v, which is local or merged, at keys in base, or an invalid value
+// if absent.
+func (m *threeWay) valueIn(v reflect.Value, keys []string) reflect.Value {
+	keys, found, err := m.keysIn(v, keys)
+	if err != nil || !found {
+		return reflect.Value{}
+	}
+
+	return valueAt(v, keys, m.o.resolver)
+}
+
+// keysIn translates keys of a path in base into keys of the path in v, which is local or merged.
+// Elements of keyed slices are matched by merge keys, in order if of the same keys, so found is
+// false if an element in the path is absent from v.
+func (m *threeWay) keysIn(v reflect.Value, keys []string) (translated []string, found bool, err error) {
+	translated = append([]string(nil), keys...)
+	t := m.hierarchy
+	for n, key := range keys {
+		index, isIndex := indexOfKey(key)
+		if !isIndex {
+			branch := t.FindBranch(key)
+			if branch == nil {
+				break
+			}
+
+			t = *branch
+			continue
+		}
+
+		base := valueAt(m.base, keys[:n], m.o.resolver)
+		for base.Kind() == reflect.Ptr || base.Kind() == reflect.Interface {
+			base = base.Elem()
+		}
+
+		if base.Kind() != reflect.Slice || index >= base.Len() {
+			break
+		}
+
+		if len(t.mergeKeys) > 0 {
+			var i int
+			if i, found, err = m.elementIn(v, translated[:n], base, index, t.mergeKeys); !found {
+				return
+			}
+
+			translated[n] = "[" + strconv.Itoa(i) + "]"
+		}
+
+		if t, found = t.elementTree(index, base.Len()); !found {
+			break
+		}
+	}
+
+	return translated, true, nil
+}
+
+// elementIn returns the index of the element in the slice at keys in v which matches the element
+// at index in base by merge keys.
+func (m *threeWay) elementIn(v reflect.Value, keys []string, base reflect.Value, index int, mergeKeys []string) (
+	int, bool, error) {
+	key, err := mergeKeyOf(base.Index(index), mergeKeys, m.o)
+	if err != nil {
+		return -1, false, err
+	}
+
+	// Elements of the same key are matched in order, so the one in v is the nth of the key.
+	nth := 0
+	for i := 0; i < index; i++ {
+		if other, err := mergeKeyOf(base.Index(i), mergeKeys, m.o); err != nil {
+			return -1, false, err
+		} else if other == key {
+			nth++
+		}
+	}
+
+	slice := valueAt(v, keys, m.o.resolver)
+	for slice.Kind() == reflect.Ptr || slice.Kind() == reflect.Interface {
+		slice = slice.Elem()
+	}
+
+	if slice.Kind() != reflect.Slice {
+		return -1, false, nil
+	}
+
+	elements, err := newKeyedElements(slice, mergeKeys, m.o)
+	if err != nil || nth >= len(elements[key]) {
+		return -1, false, err
+	}
+
+	return elements[key][nth], true, nil
+}
+
+// set sets a deep copy of value at keys in merged.
+func (m *threeWay) set(keys []string, value reflect.Value) error {
+	if value.IsValid() {
+		cpy := reflect.New(value.Type()).Elem()
+		if err := copyRecursive(value, cpy, m.o); err != nil {
+			return err
+		}
+
+		value = cpy
+	}
+
+	return setAt(m.merged, keys, value, m.o.resolver)
+}
+
+// isPrefixOf tells whether the path made of keys starts with the one made of prefix.
+func isPrefixOf(prefix, keys []string) bool {
+	if len(prefix) > len(keys) {
+		return false
+	}
+
+	for i, key := range prefix {
+		if keys[i] != key {
+			return false
+		}
+	}
+
+	return true
+}
+
+// interfaceOf returns the value in v, or nil if v is invalid.
+func interfaceOf(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	return v.Interface()
+}
+
+// valueAt returns the value at the path made of keys in v, which is invalid if absent.
+func valueAt(v reflect.Value, keys []string, resolver NameResolver) reflect.Value {
+	v, _ = pathWalker{resolver: resolver}.lookup(v, keys)
+	return v
+}
+
+// setAt sets value at the path made of keys in the settable v. If value is invalid, the value at
+// the path is removed if it is an entry of a map, or zeroed otherwise.
+func setAt(v reflect.Value, keys []string, value reflect.Value, resolver NameResolver) error {
+	if len(keys) == 0 {
+		return setValue(v, value)
+	}
+
+	w := pathWalker{resolver: resolver, promote: value.IsValid(), create: value.IsValid()}
+	err := w.update(v, keys, func(container reflect.Value, key string, s pathStep) error {
+		switch {
+		case container.Kind() == reflect.Map && !value.IsValid():
+			if !container.IsNil() {
+				container.SetMapIndex(s.key, reflect.Value{})
+			}
+
+			return nil
+		case container.Kind() == reflect.Map:
+			return updateEntry(container, s.key, func(elem reflect.Value) error {
+				return setValue(elem, value)
+			})
+		case !s.value.IsValid() && container.Kind() == reflect.Struct:
+			// The field promoted from a nil embedded pointer is zero already.
+			return nil
+		case !s.value.IsValid():
+			return &walkError{n: len(keys) - 1, reason: "contains an index out of range"}
+		}
+
+		return setValue(s.value, value)
+	})
+
+	if walkErr, ok := err.(*walkError); ok {
+		if walkErr.absent && !value.IsValid() {
+			// Values absent are removed already.
+			return nil
+		}
+
+		return walkErr.pathError(keys)
+	}
+
+	return err
+}
+
+// setValue sets value in the settable v, or zeroes v if value is invalid.
+func setValue(v reflect.Value, value reflect.Value) error {
+	switch {
+	case !value.IsValid():
+		v.Set(reflect.Zero(v.Type()))
+	case !value.Type().AssignableTo(v.Type()):
+		return &TypeMismatchError{Src: value.Type(), Dst: v.Type()}
+	default:
+		v.Set(value)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package deepcopy_test
+
+import (
+	"errors"
+	"github.com/kitt1987/deepcopy"
+	"gotest.tools/assert"
+	"testing"
+)
+
+func TestThreeWayMerge(t *testing.T) {
+	base := &patchedObject{Name: "A", Spec: &patchedSpec{
+		Image:  "v1",
+		Labels: map[string]string{"app": "A", "tier": "web"},
+		Items:  []simpleStruct{{FieldA: "A"}, {FieldA: "B"}},
+	}}
+	local := deepcopy.Clone(base)
+	local.Name = "L"
+	local.Spec.Labels["tier"] = "db"
+	local.Spec.Items[0].FieldB = 1
+	remote := deepcopy.Clone(base)
+	remote.Spec.Image = "v2"
+	remote.Spec.Labels["tier"] = "cache"
+	remote.Spec.Labels["version"] = "2"
+	remote.Spec.Items = append(remote.Spec.Items, simpleStruct{FieldA: "C"})
+	fields := []string{"Name", "Spec.Image", "Spec.Labels.tier", "Spec.Labels.version", "Spec.Items[*]"}
+
+	merged, conflicts, err := deepcopy.ThreeWayMerge(base, local, remote, fields...)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, merged, &patchedObject{Name: "L", Spec: &patchedSpec{
+		Image:  "v2",
+		Labels: map[string]string{"app": "A", "tier": "db", "version": "2"},
+		Items:  []simpleStruct{{FieldA: "A", FieldB: 1}, {FieldA: "B"}},
+	}})
+	assert.DeepEqual(t, conflicts, []deepcopy.Conflict{
+		{Path: "Spec.Items", Base: base.Spec.Items, Local: local.Spec.Items, Remote: remote.Spec.Items},
+		{Path: "Spec.Labels.tier", Base: "web", Local: "db", Remote: "cache"},
+	})
+	assert.Equal(t, local.Spec.Image, "v1")
+
+	merged, _, err = deepcopy.ThreeWayMergeWith(base, local, remote, fields,
+		deepcopy.WithConflictResolver(deepcopy.PreferRemote))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, merged.(*patchedObject).Spec.Items, remote.Spec.Items)
+	assert.Equal(t, merged.(*patchedObject).Spec.Labels["tier"], "cache")
+	assert.Equal(t, merged.(*patchedObject).Name, "L")
+
+	merged, _, err = deepcopy.ThreeWayMergeWith(base, local, remote, fields,
+		deepcopy.WithConflictResolver(func(conflict deepcopy.Conflict) (interface{}, error) {
+			if conflict.Path == "Spec.Labels.tier" {
+				return nil, nil
+			}
+
+			return conflict.Local, nil
+		}))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, merged.(*patchedObject).Spec.Labels, map[string]string{"app": "A", "version": "2"})
+
+	_, _, err = deepcopy.ThreeWayMergeWith(base, local, remote, fields,
+		deepcopy.WithConflictResolver(func(conflict deepcopy.Conflict) (interface{}, error) {
+			return nil, errors.New("unresolvable")
+		}))
+	assert.Error(t, err, "unresolvable")
+
+	local.Spec.Image = "v2"
+	merged, conflicts, err = deepcopy.ThreeWayMerge(base, local, remote, "Spec.Image")
+	assert.NilError(t, err)
+	assert.Equal(t, len(conflicts), 0)
+	assert.Equal(t, merged.(*patchedObject).Spec.Image, "v2")
+
+	_, _, err = deepcopy.ThreeWayMerge(base, local, &comparedObject{}, "Name")
+	assert.ErrorContains(t, err, "same type")
+}
+
+func TestThreeWayMergeOfFieldsSelectedAsAWhole(t *testing.T) {
+	one := 1
+	base := &patchedObject{Name: "A", Spec: &patchedSpec{
+		Replicas: &one,
+		Image:    "v1",
+		Labels:   map[string]string{"app": "A"},
+		Items:    []simpleStruct{{FieldA: "A"}},
+	}}
+	local := deepcopy.Clone(base)
+	three := 3
+	local.Spec.Replicas = &three
+	local.Spec.Labels["app"] = "L"
+	remote := deepcopy.Clone(base)
+	remote.Spec.Image = "v2"
+	remote.Spec.Labels["app"] = "R"
+	remote.Spec.Labels["tier"] = "web"
+	remote.Spec.Items[0].FieldB = 1
+
+	merged, conflicts, err := deepcopy.ThreeWayMerge(base, local, remote, "Spec")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, merged, &patchedObject{Name: "A", Spec: &patchedSpec{
+		Replicas: &three,
+		Image:    "v2",
+		Labels:   map[string]string{"app": "L", "tier": "web"},
+		Items:    []simpleStruct{{FieldA: "A", FieldB: 1}},
+	}})
+	assert.DeepEqual(t, conflicts, []deepcopy.Conflict{
+		{Path: "Spec.Labels.app", Base: "A", Local: "L", Remote: "R"},
+	})
+}
+
+func TestThreeWayMergeOfReorderedKeyedSlices(t *testing.T) {
+	base := &patchedObject{Spec: &patchedSpec{
+		Items: []simpleStruct{{FieldA: "A"}, {FieldA: "B"}, {FieldA: "C"}},
+	}}
+	local := deepcopy.Clone(base)
+	local.Spec.Items = []simpleStruct{{FieldA: "C"}, {FieldA: "B", FieldB: 2}, {FieldA: "A"}}
+	remote := deepcopy.Clone(base)
+	remote.Spec.Items[0].FieldB = 1
+	remote.Spec.Items[2].FieldB = 3
+
+	merged, conflicts, err := deepcopy.ThreeWayMergeWith(base, local, remote, []string{"Spec.Items"},
+		deepcopy.WithMergeKeys("Spec.Items", "FieldA"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(conflicts), 0)
+	assert.DeepEqual(t, merged.(*patchedObject).Spec.Items,
+		[]simpleStruct{{FieldA: "C", FieldB: 3}, {FieldA: "B", FieldB: 2}, {FieldA: "A", FieldB: 1}})
+
+	local.Spec.Items[2].FieldB = 4
+	merged, conflicts, err = deepcopy.ThreeWayMergeWith(base, local, remote, []string{"Spec.Items"},
+		deepcopy.WithMergeKeys("Spec.Items", "FieldA"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, conflicts, []deepcopy.Conflict{{Path: "Spec.Items[0].FieldB", Base: 0, Local: 4, Remote: 1}})
+	assert.DeepEqual(t, merged.(*patchedObject).Spec.Items[2], simpleStruct{FieldA: "A", FieldB: 4})
+}
@@ -0,0 +1,242 @@
+package deepcopy
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// pathWalker walks values along paths made of keys of field paths, or of tokens of JSON pointers
+// if pointer is true. Patches, merge patches and three-way merges all walk values this way.
+type pathWalker struct {
+	resolver NameResolver
+	pointer  bool
+	// promote is true if nil embedded pointers fields are promoted from are allocated on the way.
+	promote bool
+	// create is true if nil pointers and entries absent from maps are created on the way.
+	create bool
+}
+
+// pathStep is where a key of a path leads from a structure, map, slice or array.
+type pathStep struct {
+	// value is the value the key leads to, which is invalid if absent, and typ its type.
+	value reflect.Value
+	typ   reflect.Type
+	// field is the field of a structure, key the key of a map entry, and index the index of an
+	// element of a slice or array the key stands for. index is -1 if the key isn't an index.
+	field *fieldPlan
+	key   reflect.Value
+	index int
+}
+
+// walkError is an error walking through a value at the nth key of a path.
+type walkError struct {
+	n      int
+	reason string
+	// absent is true if the path goes through a value absent, which could be created.
+	absent bool
+}
+
+func (e *walkError) Error() string {
+	return e.reason
+}
+
+// pathError returns the error as an error of the field path made of keys.
+func (e *walkError) pathError(keys []string) *PathError {
+	return &PathError{Path: joinKeys(keys), Index: e.n, Reason: e.reason}
+}
+
+// visitFunc is called at the end of a path with the structure, map, slice or array the last key
+// applies to, and where the key leads.
+type visitFunc func(container reflect.Value, key string, s pathStep) error
+
+func (w pathWalker) mapKey(key string, typ reflect.Type) (reflect.Value, bool) {
+	if w.pointer {
+		return tree{kind: segmentKey, name: key}.mapKey(typ)
+	}
+
+	return branchMapKey(key, typ)
+}
+
+func (w pathWalker) index(key string) (int, bool) {
+	if w.pointer {
+		index, err := strconv.Atoi(key)
+		return index, err == nil && index >= 0
+	}
+
+	return indexOfKey(key)
+}
+
+// indirectPath strips pointers and interfaces off v of typ, where v is invalid if absent and so
+// is what nil pointers point to. ok is false if v is a nil interface, whose type is unknown.
+func indirectPath(v reflect.Value, typ reflect.Type) (_ reflect.Value, _ reflect.Type, ok bool) {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Interface {
+		if !v.IsValid() || v.IsNil() {
+			if typ.Kind() == reflect.Interface {
+				return v, typ, false
+			}
+
+			v = reflect.Value{}
+		} else {
+			v = v.Elem()
+		}
+
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		} else {
+			typ = v.Type()
+		}
+	}
+
+	return v, typ, true
+}
+
+// step returns where key, the nth of a path, leads from v, which is of typ, a structure, map,
+// slice or array, and is invalid if absent.
+func (w pathWalker) step(v reflect.Value, typ reflect.Type, key string, n int) (s pathStep, err error) {
+	switch typ.Kind() {
+	case reflect.Struct:
+		if s.field = planOf(typ).field(typ, key, w.resolver); s.field == nil {
+			return s, &walkError{n: n, reason: "contains an unknown field " + key}
+		}
+
+		s.typ = typ.FieldByIndex(s.field.index).Type
+		if v.IsValid() {
+			var detached bool
+			if s.value, _, detached = lookupField(v, key, w.resolver, w.promote); detached {
+				s.value = reflect.Value{}
+			}
+		}
+	case reflect.Map:
+		var ok bool
+		if s.key, ok = w.mapKey(key, typ); !ok {
+			return s, &walkError{n: n, reason: "contains an invalid map key " + key}
+		}
+
+		s.typ = typ.Elem()
+		if v.IsValid() && !v.IsNil() {
+			s.value = v.MapIndex(s.key)
+		}
+	case reflect.Slice, reflect.Array:
+		index, ok := w.index(key)
+		if !ok {
+			index = -1
+		}
+
+		s.index, s.typ = index, typ.Elem()
+		if v.IsValid() && ok && index < v.Len() {
+			s.value = v.Index(index)
+		}
+	default:
+		return s, &walkError{n: n, reason: "goes through a " + typ.Kind().String()}
+	}
+
+	return s, nil
+}
+
+// lookup returns the value at the path made of keys in v, which is invalid if absent. Nothing is
+// copied, so the value isn't settable unless v is and the path goes through no map.
+func (w pathWalker) lookup(v reflect.Value, keys []string) (reflect.Value, error) {
+	typ := v.Type()
+	for n, key := range keys {
+		var ok bool
+		if v, typ, ok = indirectPath(v, typ); !ok || !v.IsValid() {
+			return reflect.Value{}, nil
+		}
+
+		s, err := w.step(v, typ, key, n)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		v, typ = s.value, s.typ
+	}
+
+	return v, nil
+}
+
+// update walks the settable v along keys, which mustn't be empty, and calls visit at the end.
+// Changes visit makes are kept even in values in interfaces and entries of maps, which can't be
+// set in place.
+func (w pathWalker) update(v reflect.Value, keys []string, visit visitFunc) error {
+	return w.updateFrom(v, keys, 0, visit)
+}
+
+func (w pathWalker) updateFrom(v reflect.Value, keys []string, n int, visit visitFunc) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if !w.create {
+				return &walkError{n: n, reason: "goes through a null value", absent: true}
+			}
+
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return &walkError{n: n, reason: "goes through a null value", absent: true}
+		}
+
+		// Values in interfaces are not settable, so changes are made to a copy which is then put back.
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		if err := w.updateFrom(elem, keys, n, visit); err != nil {
+			return err
+		}
+
+		v.Set(elem)
+		return nil
+	}
+
+	key := keys[n]
+	s, err := w.step(v, v.Type(), key, n)
+	switch {
+	case err != nil:
+		return err
+	case n == len(keys)-1:
+		return visit(v, key, s)
+	}
+
+	switch {
+	case v.Kind() == reflect.Map:
+		if !s.value.IsValid() && !w.create {
+			return &walkError{n: n, reason: "contains an absent key " + key, absent: true}
+		}
+
+		return updateEntry(v, s.key, func(elem reflect.Value) error {
+			return w.updateFrom(elem, keys, n+1, visit)
+		})
+	case !s.value.IsValid() && v.Kind() == reflect.Struct:
+		return &walkError{n: n, reason: "contains an absent field " + key, absent: true}
+	case !s.value.IsValid():
+		return &walkError{n: n, reason: "contains an index out of range"}
+	}
+
+	return w.updateFrom(s.value, keys, n+1, visit)
+}
+
+// updateEntry calls update with a settable copy of the entry at key in the settable map v, which
+// is zero if absent, and then puts the copy in v unless update fails.
+func updateEntry(v, key reflect.Value, update func(elem reflect.Value) error) error {
+	// Entries of maps are not addressable, so changes are made to a copy of the entry which is
+	// then put back.
+	elem := reflect.New(v.Type().Elem()).Elem()
+	if !v.IsNil() {
+		if entry := v.MapIndex(key); entry.IsValid() {
+			elem.Set(entry)
+		}
+	}
+
+	if err := update(elem); err != nil {
+		return err
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	v.SetMapIndex(key, elem)
+	return nil
+}